@@ -0,0 +1,30 @@
+package config
+
+import "runtime/debug"
+
+// Datos de la compilación. Se inyectan al enlazar, por ejemplo:
+//
+//	go build -ldflags "-X github.com/danysoftdev/microservicio-go-mongodb/config.Version=1.2.0 \
+//	  -X github.com/danysoftdev/microservicio-go-mongodb/config.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/danysoftdev/microservicio-go-mongodb/config.FechaCompilacion=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version          = "dev"
+	Commit           = ""
+	FechaCompilacion = ""
+)
+
+func init() {
+	// Sin -ldflags, go build registra el commit y su fecha si compila dentro del repositorio
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && Commit == "":
+			Commit = s.Value
+		case s.Key == "vcs.time" && FechaCompilacion == "":
+			FechaCompilacion = s.Value
+		}
+	}
+}
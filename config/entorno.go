@@ -0,0 +1,40 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnteroDeEntorno lee una variable de entorno numérica. Si no existe o no es un
+// entero válido se usa el valor por defecto
+func EnteroDeEntorno(nombre string, porDefecto int) int {
+	valor := os.Getenv(nombre)
+	if valor == "" {
+		return porDefecto
+	}
+
+	n, err := strconv.Atoi(valor)
+	if err != nil {
+		log.Printf("⚠️ %s=%q no es un entero, se usa %d", nombre, valor, porDefecto)
+		return porDefecto
+	}
+	return n
+}
+
+// DuracionDeEntorno lee una variable de entorno con una duración como "5s" o "1m30s".
+// Si no existe, no es válida o no es positiva se usa el valor por defecto
+func DuracionDeEntorno(nombre string, porDefecto time.Duration) time.Duration {
+	valor := os.Getenv(nombre)
+	if valor == "" {
+		return porDefecto
+	}
+
+	d, err := time.ParseDuration(valor)
+	if err != nil || d <= 0 {
+		log.Printf("⚠️ %s=%q no es una duración válida, se usa %s", nombre, valor, porDefecto)
+		return porDefecto
+	}
+	return d
+}
@@ -7,11 +7,13 @@ import (
 	"time"
 	"fmt"
 
+	"github.com/danysoftdev/microservicio-go-mongodb/metricas"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var Collection *mongo.Collection
+var ColeccionHistorial *mongo.Collection
 var client *mongo.Client
 
 func ConectarMongo() error {
@@ -26,7 +28,10 @@ func ConectarMongo() error {
 		return fmt.Errorf("faltan variables de entorno: MONGO_URI, MONGO_DB o COLLECTION_NAME")
 	}
 
-	clientOptions := options.Client().ApplyURI(uri)
+	// Los monitores alimentan las métricas de comandos y del pool de conexiones
+	clientOptions := options.Client().ApplyURI(uri).
+		SetMonitor(metricas.MonitorComandos()).
+		SetPoolMonitor(metricas.MonitorPool())
 	var err error
 	client, err = mongo.Connect(ctx, clientOptions)
 	if err != nil {
@@ -40,6 +45,13 @@ func ConectarMongo() error {
 	}
 
 	Collection = client.Database(dbName).Collection(collectionName)
+
+	// El historial de cambios va en su propia colección, por defecto <COLLECTION_NAME>_historial
+	historialName := os.Getenv("HISTORIAL_COLLECTION_NAME")
+	if historialName == "" {
+		historialName = collectionName + "_historial"
+	}
+	ColeccionHistorial = client.Database(dbName).Collection(historialName)
 	log.Println("✅ Conectado a MongoDB correctamente.")
 	return nil
 }
@@ -52,3 +64,11 @@ func CerrarMongo() error {
 	}
 	return nil
 }
+
+// PingMongo comprueba que el servidor de MongoDB responda con la conexión abierta
+func PingMongo(ctx context.Context) error {
+	if client == nil {
+		return fmt.Errorf("no hay conexión con MongoDB")
+	}
+	return client.Ping(ctx, nil)
+}
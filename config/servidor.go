@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// Valores por defecto del servidor HTTP
+const (
+	DireccionPorDefecto          = ":8080"
+	TimeoutCabecerasPorDefecto   = 5 * time.Second
+	TimeoutLecturaPorDefecto     = 15 * time.Second
+	TimeoutEscrituraPorDefecto   = 30 * time.Second
+	TimeoutInactividadPorDefecto = 60 * time.Second
+	MaximoCabecerasPorDefecto    = 1 << 20
+	TiempoApagadoPorDefecto      = 20 * time.Second
+)
+
+// Servidor reúne la configuración del servidor HTTP
+type Servidor struct {
+	Direccion string
+
+	// Tiempos máximos para leer las cabeceras, leer la petición completa, escribir la
+	// respuesta y mantener abierta una conexión sin peticiones
+	TimeoutCabeceras   time.Duration
+	TimeoutLectura     time.Duration
+	TimeoutEscritura   time.Duration
+	TimeoutInactividad time.Duration
+
+	// MaximoCabeceras es el tamaño máximo en bytes de las cabeceras de una petición
+	MaximoCabeceras int
+
+	// TiempoApagado es el plazo para terminar las peticiones en curso al apagar. Debe
+	// ser menor que el tiempo que el orquestador espera antes de matar el proceso
+	TiempoApagado time.Duration
+}
+
+// CargarServidor lee la configuración del servidor HTTP de las variables de entorno
+func CargarServidor() Servidor {
+	direccion := os.Getenv("HTTP_DIRECCION")
+	if direccion == "" {
+		direccion = DireccionPorDefecto
+	}
+
+	return Servidor{
+		Direccion:          direccion,
+		TimeoutCabeceras:   DuracionDeEntorno("HTTP_TIMEOUT_CABECERAS", TimeoutCabecerasPorDefecto),
+		TimeoutLectura:     DuracionDeEntorno("HTTP_TIMEOUT_LECTURA", TimeoutLecturaPorDefecto),
+		TimeoutEscritura:   DuracionDeEntorno("HTTP_TIMEOUT_ESCRITURA", TimeoutEscrituraPorDefecto),
+		TimeoutInactividad: DuracionDeEntorno("HTTP_TIMEOUT_INACTIVIDAD", TimeoutInactividadPorDefecto),
+		MaximoCabeceras:    EnteroDeEntorno("HTTP_MAX_BYTES_CABECERAS", MaximoCabecerasPorDefecto),
+		TiempoApagado:      DuracionDeEntorno("HTTP_TIEMPO_APAGADO", TiempoApagadoPorDefecto),
+	}
+}
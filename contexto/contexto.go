@@ -0,0 +1,32 @@
+package contexto
+
+import "context"
+
+type clave int
+
+const (
+	claveRequestID clave = iota
+	claveUsuario
+)
+
+// ConRequestID devuelve un contexto que lleva el identificador de la petición
+func ConRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, claveRequestID, id)
+}
+
+// RequestID devuelve el identificador de la petición o "" si no lo hay
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(claveRequestID).(string)
+	return id
+}
+
+// ConUsuario devuelve un contexto que lleva el usuario autenticado que hace la petición
+func ConUsuario(ctx context.Context, usuario string) context.Context {
+	return context.WithValue(ctx, claveUsuario, usuario)
+}
+
+// Usuario devuelve el usuario autenticado o "" si la petición no trae uno
+func Usuario(ctx context.Context) string {
+	usuario, _ := ctx.Value(claveUsuario).(string)
+	return usuario
+}
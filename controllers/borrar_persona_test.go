@@ -13,6 +13,9 @@ import (
 	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func TestEliminarPersonaController_Success(t *testing.T) {
@@ -20,10 +23,11 @@ func TestEliminarPersonaController_Success(t *testing.T) {
 	services.SetPersonaRepository(mockRepo)
 
 	doc := "123"
-	mockRepo.On("ObtenerPersonaPorDocumento", doc).Return(models.Persona{Documento: doc}, nil)
-	mockRepo.On("EliminarPersona", doc).Return(nil)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, doc).Return(models.Persona{ID: idPersona, Documento: doc, Version: 4}, nil)
+	mockRepo.On("ActualizarCampos", mock.Anything, models.CC, doc, int64(4), mock.Anything).Return(nil)
 
 	req := httptest.NewRequest("DELETE", "/personas/"+doc, nil)
+	req.Header.Set("If-Match", etag(idPersona, 4))
 	req = mux.SetURLVars(req, map[string]string{"documento": doc})
 	rr := httptest.NewRecorder()
 
@@ -39,34 +43,87 @@ func TestEliminarPersonaController_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestEliminarPersonaController_NoEncontrada(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "789").Return(models.Persona{}, mongo.ErrNoDocuments)
+
+	req := httptest.NewRequest("DELETE", "/personas/789", nil)
+	req.Header.Set("If-Match", etag(idPersona, 4))
+	req = mux.SetURLVars(req, map[string]string{"documento": "789"})
+	rr := httptest.NewRecorder()
+
+	controllers.EliminarPersona(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "persona no encontrada")
+	mockRepo.AssertExpectations(t)
+}
+
 func TestEliminarPersonaController_DocumentoVacio(t *testing.T) {
 	req := httptest.NewRequest("DELETE", "/personas/", nil)
+	req.Header.Set("If-Match", etag(idPersona, 4))
 	req = mux.SetURLVars(req, map[string]string{"documento": ""})
 	rr := httptest.NewRecorder()
 
 	controllers.EliminarPersona(rr, req)
 
-	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
 	assert.Contains(t, rr.Body.String(), "el documento no puede estar vacío")
 }
 
-
 func TestEliminarPersonaController_ErrorEliminar(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.SetPersonaRepository(mockRepo)
 
 	doc := "456"
-	mockRepo.On("ObtenerPersonaPorDocumento", doc).Return(models.Persona{Documento: doc}, nil)
-	mockRepo.On("EliminarPersona", doc).Return(errors.New("fallo al eliminar"))
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, doc).Return(models.Persona{ID: idPersona, Documento: doc, Version: 4}, nil)
+	mockRepo.On("ActualizarCampos", mock.Anything, models.CC, doc, int64(4), mock.Anything).Return(errors.New("fallo al eliminar"))
 
 	req := httptest.NewRequest("DELETE", "/personas/"+doc, nil)
+	req.Header.Set("If-Match", etag(idPersona, 4))
 	req = mux.SetURLVars(req, map[string]string{"documento": doc})
 	rr := httptest.NewRecorder()
 
 	controllers.EliminarPersona(rr, req)
 
-	assert.Equal(t, http.StatusBadRequest, rr.Code)
-	assert.Contains(t, rr.Body.String(), "fallo al eliminar")
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Error al eliminar la persona")
+	assert.NotContains(t, rr.Body.String(), "fallo al eliminar")
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestEliminarPersonaController_SinIfMatch(t *testing.T) {
+	req := httptest.NewRequest("DELETE", "/personas/123", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.EliminarPersona(rr, req)
+
+	assert.Equal(t, http.StatusPreconditionRequired, rr.Code)
+	assert.Contains(t, rr.Body.String(), "If-Match")
+}
+
+func TestEliminarPersonaController_VersionDesactualizada(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{ID: idPersona, Documento: "123", Version: 5}, nil)
+
+	// La misma versión de otra persona que ocupó el documento antes de purgarse
+	// tampoco coincide
+	otra := primitive.NewObjectID()
+	for _, ifMatch := range []string{etag(idPersona, 4), "W/" + etag(idPersona, 5), etag(otra, 5), `"5"`, idPersona.Hex() + "-5"} {
+		req := httptest.NewRequest("DELETE", "/personas/123", nil)
+		req.Header.Set("If-Match", ifMatch)
+		req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+		rr := httptest.NewRecorder()
+
+		controllers.EliminarPersona(rr, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code, ifMatch)
+	}
+	mockRepo.AssertNotCalled(t, "ActualizarCampos", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
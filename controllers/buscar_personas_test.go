@@ -1,19 +1,23 @@
 package controllers_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/danysoftdev/microservicio-go-mongodb/contexto"
 	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
 	"github.com/danysoftdev/microservicio-go-mongodb/models"
 	"github.com/danysoftdev/microservicio-go-mongodb/services"
 	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
-	"github.com/stretchr/testify/assert"
 	"github.com/gorilla/mux"
-
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func TestObtenerPersonaPorDocumento_Success(t *testing.T) {
@@ -21,16 +25,19 @@ func TestObtenerPersonaPorDocumento_Success(t *testing.T) {
 	services.SetPersonaRepository(mockRepo)
 
 	personaEsperada := models.Persona{
-		Documento: "12345",
-		Nombre:    "Ana",
-		Apellido:  "Díaz",
-		Edad:      30,
-		Correo:    "ana@correo.com",
-		Telefono:  "3001234567",
-		Direccion: "Calle Falsa",
+		TipoDocumento: models.CC,
+		Documento:     "12345",
+		Nombre:        "Ana",
+		Apellido:      "Díaz",
+		Edad:          30,
+		Correo:        "ana@correo.com",
+		Telefono:      "3001234567",
+		Direccion:     "Calle Falsa",
+		ID:            idPersona,
+		Version:       7,
 	}
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "12345").Return(personaEsperada, nil)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "12345").Return(personaEsperada, nil)
 
 	req := httptest.NewRequest("GET", "/personas/12345", nil)
 	req = mux.SetURLVars(req, map[string]string{"documento": "12345"})
@@ -39,6 +46,7 @@ func TestObtenerPersonaPorDocumento_Success(t *testing.T) {
 	controllers.ObtenerPersonaPorDocumento(rec, req)
 
 	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, etag(idPersona, 7), rec.Header().Get("ETag"))
 
 	var resp models.Persona
 	json.NewDecoder(rec.Body).Decode(&resp)
@@ -46,11 +54,27 @@ func TestObtenerPersonaPorDocumento_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestObtenerPersonaPorDocumento_NormalizaElDocumento(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.NIT, "9001234567").Return(models.Persona{Version: 1}, nil)
+
+	req := httptest.NewRequest("GET", "/personas/900123456-7?tipo_documento=nit", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "900123456-7"})
+
+	rec := httptest.NewRecorder()
+	controllers.ObtenerPersonaPorDocumento(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestObtenerPersonaPorDocumento_NotFound(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.SetPersonaRepository(mockRepo)
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "99999").Return(models.Persona{}, errors.New("persona no encontrada"))
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "99999").Return(models.Persona{}, mongo.ErrNoDocuments)
 
 	req := httptest.NewRequest("GET", "/personas/99999", nil)
 	req = mux.SetURLVars(req, map[string]string{"documento": "99999"})
@@ -62,3 +86,113 @@ func TestObtenerPersonaPorDocumento_NotFound(t *testing.T) {
 	assert.Contains(t, rec.Body.String(), "persona no encontrada")
 	mockRepo.AssertExpectations(t)
 }
+
+func TestObtenerPersonaPorDocumento_ErrorBaseDeDatos(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "12345").Return(models.Persona{}, errors.New("servidor no disponible"))
+
+	req := httptest.NewRequest("GET", "/personas/12345", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "12345"})
+
+	rec := httptest.NewRecorder()
+	controllers.ObtenerPersonaPorDocumento(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Error al buscar la persona")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestObtenerPersonaPorDocumento_ConTipo(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.TI, "1020304050").
+		Return(models.Persona{TipoDocumento: models.TI, Documento: "1020304050", Nombre: "Luis"}, nil)
+
+	req := httptest.NewRequest("GET", "/personas/1020304050?tipo_documento=ti", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "1020304050"})
+
+	rec := httptest.NewRecorder()
+	controllers.ObtenerPersonaPorDocumento(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"tipo_documento":"TI"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestObtenerPersonaPorDocumento_TipoInvalido(t *testing.T) {
+	req := httptest.NewRequest("GET", "/personas/123?tipo_documento=XX", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+
+	rec := httptest.NewRecorder()
+	controllers.ObtenerPersonaPorDocumento(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "el tipo de documento XX no es válido")
+}
+
+func TestObtenerPersonaPorDocumento_PropagaContexto(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	// El repositorio debe recibir el contexto de la petición, no uno nuevo
+	delaPeticion := mock.MatchedBy(func(ctx context.Context) bool {
+		return contexto.RequestID(ctx) == "abc123"
+	})
+	mockRepo.On("ObtenerPersonaPorDocumento", delaPeticion, models.CC, "12345").Return(models.Persona{Documento: "12345"}, nil)
+
+	req := httptest.NewRequest("GET", "/personas/12345", nil)
+	req = req.WithContext(contexto.ConRequestID(req.Context(), "abc123"))
+	req = mux.SetURLVars(req, map[string]string{"documento": "12345"})
+
+	rec := httptest.NewRecorder()
+	controllers.ObtenerPersonaPorDocumento(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestObtenerPersonaPorDocumento_Condicional(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "12345").
+		Return(models.Persona{ID: idPersona, Documento: "12345", Version: 3, ActualizadoEn: instante.Add(250 * time.Millisecond)}, nil)
+
+	casos := []struct {
+		nombre   string
+		cabecera map[string]string
+		estado   int
+	}{
+		{"sin condiciones", nil, http.StatusOK},
+		{"misma ETag", map[string]string{"If-None-Match": etag(idPersona, 3)}, http.StatusNotModified},
+		{"ETag débil en una lista", map[string]string{"If-None-Match": etag(idPersona, 1) + ", W/" + etag(idPersona, 3)}, http.StatusNotModified},
+		{"ETag vieja", map[string]string{"If-None-Match": etag(idPersona, 2)}, http.StatusOK},
+		{"misma versión de otra persona", map[string]string{"If-None-Match": `"3"`}, http.StatusOK},
+		{"sin cambios desde la fecha", map[string]string{"If-Modified-Since": instante.Format(http.TimeFormat)}, http.StatusNotModified},
+		{"cambió después de la fecha", map[string]string{"If-Modified-Since": instante.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		{"la ETag tiene prioridad sobre la fecha", map[string]string{"If-None-Match": etag(idPersona, 2), "If-Modified-Since": instante.Format(http.TimeFormat)}, http.StatusOK},
+	}
+
+	for _, tt := range casos {
+		t.Run(tt.nombre, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/personas/12345", nil)
+			for clave, valor := range tt.cabecera {
+				req.Header.Set(clave, valor)
+			}
+			req = mux.SetURLVars(req, map[string]string{"documento": "12345"})
+
+			rec := httptest.NewRecorder()
+			controllers.ObtenerPersonaPorDocumento(rec, req)
+
+			assert.Equal(t, tt.estado, rec.Code)
+			assert.Equal(t, etag(idPersona, 3), rec.Header().Get("ETag"))
+			assert.Equal(t, "Fri, 15 Mar 2024 10:30:00 GMT", rec.Header().Get("Last-Modified"))
+			if tt.estado == http.StatusNotModified {
+				assert.Empty(t, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBuscarPersonasController_Success(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("BuscarPersonas", mock.Anything, "Jose Perez", 10).
+		Return([]models.Persona{{Documento: "1", Nombre: "José", Apellido: "Pérez"}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/personas/search?q=Jose+Perez&limit=10", nil)
+	rr := httptest.NewRecorder()
+
+	controllers.BuscarPersonas(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var respuesta []models.Persona
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&respuesta))
+	assert.Len(t, respuesta, 1)
+	assert.Equal(t, "Pérez", respuesta[0].Apellido)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBuscarPersonasController_SinTexto(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/personas/search", nil)
+	rr := httptest.NewRecorder()
+
+	controllers.BuscarPersonas(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "el texto de búsqueda no puede estar vacío")
+}
+
+func TestBuscarPersonasController_LimiteInvalido(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/personas/search?q=ana&limit=diez", nil)
+	rr := httptest.NewRecorder()
+
+	controllers.BuscarPersonas(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "el parámetro limit debe ser un número entero")
+}
+
+func TestBuscarPersonasController_Error(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("BuscarPersonas", mock.Anything, "ana", services.LimitePorDefecto).Return([]models.Persona(nil), errors.New("fallo"))
+
+	req := httptest.NewRequest(http.MethodGet, "/personas/search?q=ana", nil)
+	rr := httptest.NewRecorder()
+
+	controllers.BuscarPersonas(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Error al buscar personas")
+	mockRepo.AssertExpectations(t)
+}
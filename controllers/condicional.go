@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// noModificado publica la ETag y la fecha de modificación de la respuesta y, si el
+// cliente ya tiene esa misma versión, responde 304 y devuelve true.
+// Como indica la RFC 9110, If-None-Match tiene prioridad sobre If-Modified-Since
+func noModificado(w http.ResponseWriter, r *http.Request, etag string, modificado time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !modificado.IsZero() {
+		w.Header().Set("Last-Modified", modificado.UTC().Format(http.TimeFormat))
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if !coincideEtag(ifNoneMatch, etag) {
+			return false
+		}
+	} else if !sinCambiosDesde(r.Header.Get("If-Modified-Since"), modificado) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// coincideEtag aplica la comparación débil de If-None-Match sobre la lista de ETags del cliente
+func coincideEtag(lista, etag string) bool {
+	for _, candidata := range strings.Split(lista, ",") {
+		candidata = strings.TrimSpace(candidata)
+		if candidata == "*" || strings.TrimPrefix(candidata, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sinCambiosDesde indica si el recurso no cambió después de la fecha de If-Modified-Since.
+// Las fechas HTTP tienen precisión de segundos, así que se compara sin fracciones
+func sinCambiosDesde(ifModifiedSince string, modificado time.Time) bool {
+	if ifModifiedSince == "" || modificado.IsZero() {
+		return false
+	}
+	desde, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	return !modificado.Truncate(time.Second).After(desde)
+}
+
+// etagDeContenido arma una ETag fuerte a partir del cuerpo exacto que se va a enviar
+func etagDeContenido(cuerpo []byte) string {
+	suma := sha256.Sum256(cuerpo)
+	return `"` + hex.EncodeToString(suma[:16]) + `"`
+}
+
+// escribirJSONCondicional envía el cuerpo con una ETag calculada sobre su contenido,
+// o un 304 si el cliente ya lo tiene
+func escribirJSONCondicional(w http.ResponseWriter, r *http.Request, cuerpo any) {
+	datos, err := json.Marshal(cuerpo)
+	if err != nil {
+		escribirError(w, r, err, "Error al serializar la respuesta")
+		return
+	}
+
+	// Un listado también cambia cuando se borra una persona, lo que no deja rastro
+	// en ninguna fecha, así que aquí solo se valida con la ETag
+	if noModificado(w, r, etagDeContenido(datos), time.Time{}) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(datos, '\n'))
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+)
+
+// tiposLote son los formatos que acepta la creación masiva: un arreglo JSON o
+// NDJSON, una persona por línea
+var tiposLote = []string{"application/json", "application/x-ndjson", "application/ndjson"}
+
+// tamanoMaximoLote es el tamaño máximo del cuerpo de una creación masiva. Alcanza para
+// services.MaximoLote personas con holgura, y evita leer a memoria un cuerpo enorme
+// antes de poder rechazarlo
+const tamanoMaximoLote = 10 << 20
+
+// CrearPersonas crea un lote de personas y responde el resultado de cada una.
+// Con atomic=true se crean todas o ninguna
+func CrearPersonas(w http.ResponseWriter, r *http.Request) {
+	atomica, err := leerBooleano(r.URL.Query(), "atomic")
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+
+	tipoContenido, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !slices.Contains(tiposLote, tipoContenido) {
+		w.Header().Set("Accept", strings.Join(tiposLote, ", "))
+		escribirProblema(w, r, http.StatusUnsupportedMediaType, "El cuerpo debe ser un arreglo JSON o NDJSON", nil)
+		return
+	}
+
+	personas, err := leerLote(http.MaxBytesReader(w, r.Body, tamanoMaximoLote), tipoContenido)
+	if errors.Is(err, services.ErrLoteDemasiadoGrande) {
+		escribirError(w, r, err, "Error al leer el lote")
+		return
+	}
+	if err != nil {
+		escribirProblema(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if len(personas) == 0 {
+		escribirProblema(w, r, http.StatusBadRequest, "El lote no trae personas", nil)
+		return
+	}
+
+	resultados, err := services.CrearPersonas(r.Context(), personas, atomica)
+	if err != nil {
+		escribirError(w, r, err, "Error al crear las personas")
+		return
+	}
+
+	respuesta := models.RespuestaLote{Resultados: make([]models.ResultadoLote, len(resultados))}
+	for i, resultado := range resultados {
+		respuesta.Resultados[i] = resultadoLote(r, i, resultado)
+		if resultado.Err == nil {
+			respuesta.Creadas++
+		} else {
+			respuesta.Fallidas++
+		}
+	}
+
+	// Si alguna persona falló, cada una lleva su propio código en el resultado
+	estado := http.StatusCreated
+	if respuesta.Fallidas > 0 {
+		estado = http.StatusMultiStatus
+	}
+	escribirJSON(w, estado, respuesta)
+}
+
+// leerLote decodifica las personas del cuerpo según su formato, una por una, y se
+// detiene con ErrLoteDemasiadoGrande al llegar la persona MaximoLote+1 o al superar
+// el tamaño máximo del cuerpo
+func leerLote(cuerpo io.Reader, tipoContenido string) ([]models.Persona, error) {
+	decoder := json.NewDecoder(cuerpo)
+	var personas []models.Persona
+
+	if tipoContenido == "application/json" {
+		errArreglo := errors.New("el cuerpo debe ser un arreglo JSON de personas")
+		if inicio, err := decoder.Token(); err != nil || inicio != json.Delim('[') {
+			return nil, errorDeLectura(err, errArreglo)
+		}
+		for decoder.More() {
+			var persona models.Persona
+			if err := decoder.Decode(&persona); err != nil {
+				return nil, errorDeLectura(err, errArreglo)
+			}
+			if len(personas) == services.MaximoLote {
+				return nil, errLoteExcedido
+			}
+			personas = append(personas, persona)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, errorDeLectura(err, errArreglo)
+		}
+		return personas, nil
+	}
+
+	// En NDJSON cada línea es un documento; el decoder ignora los saltos de línea entre ellos
+	for {
+		var persona models.Persona
+		err := decoder.Decode(&persona)
+		if err == io.EOF {
+			return personas, nil
+		}
+		if err != nil {
+			return nil, errorDeLectura(err, fmt.Errorf("la persona %d del NDJSON no es un objeto JSON válido", len(personas)+1))
+		}
+		if len(personas) == services.MaximoLote {
+			return nil, errLoteExcedido
+		}
+		personas = append(personas, persona)
+	}
+}
+
+// errLoteExcedido indica cuántas personas admite un lote
+var errLoteExcedido = fmt.Errorf("%w: el máximo es %d", services.ErrLoteDemasiadoGrande, services.MaximoLote)
+
+// errorDeLectura distingue un cuerpo que superó tamanoMaximoLote, que se rechaza como
+// un lote demasiado grande, de uno mal formado, que se responde con errFormato
+func errorDeLectura(err, errFormato error) error {
+	var excedido *http.MaxBytesError
+	if errors.As(err, &excedido) {
+		return fmt.Errorf("%w: el cuerpo supera los %d bytes", services.ErrLoteDemasiadoGrande, excedido.Limit)
+	}
+	return errFormato
+}
+
+// resultadoLote arma el resultado de una persona con el mismo código y mensaje
+// que habría recibido si se creara sola
+func resultadoLote(r *http.Request, indice int, resultado services.ResultadoCreacion) models.ResultadoLote {
+	if resultado.Err == nil {
+		return models.ResultadoLote{Indice: indice, Estado: http.StatusCreated, ID: resultado.ID.Hex()}
+	}
+
+	estado := estadoDeError(resultado.Err)
+	if estado == http.StatusInternalServerError {
+		log.Printf("❌ Error al crear la persona %d del lote [%s]: %v", indice, requestID(r), resultado.Err)
+		return models.ResultadoLote{Indice: indice, Estado: estado, Error: "Error al crear la persona"}
+	}
+	return models.ResultadoLote{Indice: indice, Estado: estado, Error: resultado.Err.Error(), Errores: erroresDeCampo(resultado.Err)}
+}
@@ -3,7 +3,6 @@ package controllers_test
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -13,26 +12,33 @@ import (
 	"github.com/danysoftdev/microservicio-go-mongodb/services"
 	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-
 func TestCrearPersonaController_Success(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.SetPersonaRepository(mockRepo)
 
 	persona := models.Persona{
-		Documento: "123",
-		Nombre:    "Laura",
-		Apellido:  "Gómez",
-		Edad:      30,
-		Correo:    "laura@example.com",
-		Telefono:  "123456789",
-		Direccion: "Calle Falsa 123",
+		TipoDocumento:  models.CC,
+		Documento:      "123",
+		Nombre:         "Laura",
+		Apellido:       "Gómez",
+		Edad:           30,
+		Correo:         "laura@example.com",
+		Telefono:       "+573001234567",
+		Direccion:      "Calle Falsa 123",
+		Version:        models.VersionInicial,
+		CreadoEn:       instante,
+		CreadoPor:      services.UsuarioAnonimo,
+		ActualizadoEn:  instante,
+		ActualizadoPor: services.UsuarioAnonimo,
 	}
 
-	// Mock de flujo exitoso: no existe, y se inserta correctamente
-	mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(models.Persona{}, errors.New("no encontrado"))
-	mockRepo.On("InsertarPersona", persona).Return(nil)
+	// Mock de flujo exitoso: se inserta correctamente
+	id := primitive.NewObjectID()
+	mockRepo.On("InsertarPersona", mock.Anything, persona).Return(id, nil)
 
 	body, _ := json.Marshal(persona)
 	req := httptest.NewRequest(http.MethodPost, "/personas", bytes.NewBuffer(body))
@@ -42,7 +48,13 @@ func TestCrearPersonaController_Success(t *testing.T) {
 	controllers.CrearPersona(rr, req)
 
 	assert.Equal(t, http.StatusCreated, rr.Code)
-	assert.Contains(t, rr.Body.String(), "Persona creada exitosamente")
+	assert.Equal(t, "/api/v1/personas/123", rr.Header().Get("Location"))
+	assert.Equal(t, etag(id, 1), rr.Header().Get("ETag"))
+
+	var creada models.Persona
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &creada))
+	persona.ID = id
+	assert.Equal(t, persona, creada)
 	mockRepo.AssertExpectations(t)
 }
 
@@ -51,16 +63,22 @@ func TestCrearPersonaController_DocumentoExistente(t *testing.T) {
 	services.SetPersonaRepository(mockRepo)
 
 	existente := models.Persona{
-		Documento: "123",
-		Nombre:    "Existente",
-		Apellido:  "Persona",
-		Edad:      25,
-		Correo:    "existente@example.com",
-		Telefono:  "987654321",
-		Direccion: "Otra calle",
+		TipoDocumento:  models.CC,
+		Documento:      "123",
+		Nombre:         "Existente",
+		Apellido:       "Persona",
+		Edad:           25,
+		Correo:         "existente@example.com",
+		Telefono:       "+573009876543",
+		Direccion:      "Otra calle",
+		Version:        models.VersionInicial,
+		CreadoEn:       instante,
+		CreadoPor:      services.UsuarioAnonimo,
+		ActualizadoEn:  instante,
+		ActualizadoPor: services.UsuarioAnonimo,
 	}
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(existente, nil)
+	mockRepo.On("InsertarPersona", mock.Anything, existente).Return(primitive.NilObjectID, mocks.ErrLlaveDuplicada)
 
 	body, _ := json.Marshal(existente)
 	req := httptest.NewRequest(http.MethodPost, "/personas", bytes.NewBuffer(body))
@@ -69,7 +87,7 @@ func TestCrearPersonaController_DocumentoExistente(t *testing.T) {
 	rr := httptest.NewRecorder()
 	controllers.CrearPersona(rr, req)
 
-	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, http.StatusConflict, rr.Code)
 	assert.Contains(t, rr.Body.String(), "ya existe una persona con ese documento")
 	mockRepo.AssertExpectations(t)
 }
@@ -85,5 +103,55 @@ func TestCrearPersonaController_JSONInvalido(t *testing.T) {
 	controllers.CrearPersona(rr, req)
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
 	assert.Contains(t, rr.Body.String(), "El formato del cuerpo es inválido")
-}
\ No newline at end of file
+}
+
+func TestCrearPersonaController_DatosInvalidos(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	body, _ := json.Marshal(models.Persona{Documento: "123"})
+	req := httptest.NewRequest(http.MethodPost, "/personas", bytes.NewBuffer(body))
+	req.Header.Set("X-Request-ID", "req-123")
+	rr := httptest.NewRecorder()
+
+	controllers.CrearPersona(rr, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+	var problema models.Problema
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&problema))
+	assert.Equal(t, http.StatusUnprocessableEntity, problema.Estado)
+	assert.Equal(t, "/personas", problema.Instancia)
+	assert.Equal(t, "req-123", problema.RequestID)
+
+	// Se informan todos los campos inválidos a la vez
+	campos := []string{}
+	for _, e := range problema.Errores {
+		campos = append(campos, e.Campo)
+	}
+	assert.Equal(t, []string{"nombre", "apellido", "edad", "correo", "telefono", "direccion"}, campos)
+	assert.Equal(t, models.ErrorCampo{Campo: "nombre", Regla: "requerido", Mensaje: "el nombre no puede estar vacío"}, problema.Errores[0])
+	mockRepo.AssertNotCalled(t, "InsertarPersona", mock.Anything, mock.Anything)
+}
+
+func TestCrearPersonaController_IgnoraEliminadoEn(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	// Una fecha de eliminación enviada por el cliente crearía la persona ya eliminada
+	mockRepo.On("InsertarPersona", mock.Anything, mock.MatchedBy(func(p models.Persona) bool {
+		return p.EliminadoEn.IsZero()
+	})).Return(primitive.NewObjectID(), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/personas", bytes.NewBufferString(`{"tipo_documento": "CC", "documento": "123", "nombre": "Juan", "apellido": "Pérez", "edad": 30, "correo": "juan@example.com", "telefono": "3001234567", "direccion": "Calle Falsa 123", "eliminado_en": "2000-01-01T00:00:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	controllers.CrearPersona(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "eliminado_en")
+	mockRepo.AssertExpectations(t)
+}
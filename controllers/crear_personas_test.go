@@ -0,0 +1,130 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const personaLoteJSON = `{"documento": "%s", "nombre": "Juan", "apellido": "Pérez", "edad": 30, "correo": "juan@example.com", "telefono": "3001234567", "direccion": "Calle 123"}`
+
+func personaLote(documento string) string {
+	return fmt.Sprintf(personaLoteJSON, documento)
+}
+
+func nuevaPeticionLote(ruta, cuerpo, tipoContenido string) *http.Request {
+	req := httptest.NewRequest("POST", ruta, strings.NewReader(cuerpo))
+	req.Header.Set("Content-Type", tipoContenido)
+	return req
+}
+
+func TestCrearPersonasController_Arreglo(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("InsertarPersonas", mock.Anything, mock.Anything, false).Return(nil)
+
+	cuerpo := "[" + personaLote("123") + "," + personaLote("456") + "]"
+	rr := httptest.NewRecorder()
+	controllers.CrearPersonas(rr, nuevaPeticionLote("/personas/bulk", cuerpo, "application/json"))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var respuesta models.RespuestaLote
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &respuesta))
+	assert.Equal(t, 2, respuesta.Creadas)
+	assert.Equal(t, http.StatusCreated, respuesta.Resultados[1].Estado)
+	assert.NotEmpty(t, respuesta.Resultados[1].ID)
+}
+
+func TestCrearPersonasController_NDJSONConFallas(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("InsertarPersonas", mock.Anything, mock.Anything, false).Return(nil)
+
+	cuerpo := personaLote("123") + "\n" + strings.Replace(personaLote("456"), `"edad": 30`, `"edad": 0`, 1) + "\n"
+	rr := httptest.NewRecorder()
+	controllers.CrearPersonas(rr, nuevaPeticionLote("/personas/bulk", cuerpo, "application/x-ndjson"))
+
+	assert.Equal(t, http.StatusMultiStatus, rr.Code)
+	var respuesta models.RespuestaLote
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &respuesta))
+	assert.Equal(t, 1, respuesta.Creadas)
+	assert.Equal(t, 1, respuesta.Fallidas)
+	assert.Equal(t, http.StatusUnprocessableEntity, respuesta.Resultados[1].Estado)
+	assert.Equal(t, "edad", respuesta.Resultados[1].Errores[0].Campo)
+}
+
+func TestCrearPersonasController_Atomico(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	cuerpo := "[" + personaLote("123") + "," + personaLote("") + "]"
+	rr := httptest.NewRecorder()
+	controllers.CrearPersonas(rr, nuevaPeticionLote("/personas/bulk?atomic=true", cuerpo, "application/json"))
+
+	assert.Equal(t, http.StatusMultiStatus, rr.Code)
+	var respuesta models.RespuestaLote
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &respuesta))
+	assert.Equal(t, 0, respuesta.Creadas)
+	assert.Equal(t, http.StatusFailedDependency, respuesta.Resultados[0].Estado)
+	assert.Equal(t, http.StatusUnprocessableEntity, respuesta.Resultados[1].Estado)
+	mockRepo.AssertNotCalled(t, "InsertarPersonas", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCrearPersonasController_CuerpoInvalido(t *testing.T) {
+	casos := []struct {
+		cuerpo, tipoContenido string
+		estado                int
+	}{
+		{personaLote("123"), "application/json", http.StatusBadRequest},
+		{"[]", "application/json", http.StatusBadRequest},
+		{personaLote("123") + "\n{invalido", "application/x-ndjson", http.StatusBadRequest},
+		{"documento,nombre", "text/csv", http.StatusUnsupportedMediaType},
+	}
+
+	for _, caso := range casos {
+		rr := httptest.NewRecorder()
+		controllers.CrearPersonas(rr, nuevaPeticionLote("/personas/bulk", caso.cuerpo, caso.tipoContenido))
+
+		assert.Equal(t, caso.estado, rr.Code, caso.cuerpo)
+	}
+}
+
+func TestCrearPersonasController_LoteDemasiadoGrande(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	personas := make([]string, services.MaximoLote+1)
+	for i := range personas {
+		personas[i] = personaLote(fmt.Sprint(100000 + i))
+	}
+	// Un solo objeto que por sí solo supera el tamaño máximo del cuerpo
+	enorme := `{"nombre": "` + strings.Repeat("a", 11<<20) + `"}`
+
+	casos := map[string]struct{ cuerpo, tipoContenido string }{
+		"arreglo con una persona de más": {"[" + strings.Join(personas, ",") + "]", "application/json"},
+		"NDJSON con una persona de más":  {strings.Join(personas, "\n"), "application/x-ndjson"},
+		"arreglo que supera el tamaño":   {"[" + enorme + "]", "application/json"},
+		"NDJSON que supera el tamaño":    {enorme, "application/x-ndjson"},
+	}
+
+	for nombre, caso := range casos {
+		t.Run(nombre, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			controllers.CrearPersonas(rr, nuevaPeticionLote("/personas/bulk", caso.cuerpo, caso.tipoContenido))
+
+			assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+			assert.Contains(t, rr.Body.String(), "el lote supera la cantidad máxima de personas")
+		})
+	}
+	mockRepo.AssertNotCalled(t, "InsertarPersonas", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -14,13 +14,12 @@ import (
 	"testing"
 	"time"
 
-	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/danysoftdev/microservicio-go-mongodb/config"
-	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
 	"github.com/danysoftdev/microservicio-go-mongodb/models"
 	"github.com/danysoftdev/microservicio-go-mongodb/repositories"
+	"github.com/danysoftdev/microservicio-go-mongodb/routes"
 	"github.com/danysoftdev/microservicio-go-mongodb/services"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
@@ -55,33 +54,29 @@ func TestEndpointsControllerIntegration(t *testing.T) {
 	repositories.SetCollection(config.Collection)
 	services.SetPersonaRepository(repositories.RealPersonaRepository{})
 
-	// Setup router
-	router := mux.NewRouter()
-	router.HandleFunc("/personas", controllers.CrearPersona).Methods("POST")
-	router.HandleFunc("/personas", controllers.ObtenerPersonas).Methods("GET")
-	router.HandleFunc("/personas/{documento}", controllers.ObtenerPersonaPorDocumento).Methods("GET")
-	router.HandleFunc("/personas/{documento}", controllers.ActualizarPersona).Methods("PUT")
-	router.HandleFunc("/personas/{documento}", controllers.EliminarPersona).Methods("DELETE")
+	router := routes.NuevoRouter()
 
 	// 1. Crear persona
 	persona := models.Persona{
-		Documento: "999",
-		Nombre:    "Test",
-		Apellido:  "Integration",
-		Edad:      33,
-		Correo:    "test@integration.com",
-		Telefono:  "1111111111",
-		Direccion: "Calle Test",
+		TipoDocumento: models.CC,
+		Documento:     "999",
+		Nombre:        "Test",
+		Apellido:      "Integration",
+		Edad:          33,
+		Correo:        "test@integration.com",
+		Telefono:      "3001111111",
+		Direccion:     "Calle Test",
 	}
 	body, _ := json.Marshal(persona)
-	reqCrear := httptest.NewRequest("POST", "/personas", bytes.NewReader(body))
+	reqCrear := httptest.NewRequest("POST", "/api/v1/personas", bytes.NewReader(body))
 	resCrear := httptest.NewRecorder()
 	router.ServeHTTP(resCrear, reqCrear)
 
 	assert.Equal(t, http.StatusCreated, resCrear.Code)
+	assert.Equal(t, "/api/v1/personas/999", resCrear.Header().Get("Location"))
 
 	// 2. Obtener todas
-	reqObtener := httptest.NewRequest("GET", "/personas", nil)
+	reqObtener := httptest.NewRequest("GET", "/api/v1/personas", nil)
 	resObtener := httptest.NewRecorder()
 	router.ServeHTTP(resObtener, reqObtener)
 
@@ -90,26 +85,46 @@ func TestEndpointsControllerIntegration(t *testing.T) {
 	assert.Contains(t, string(content), "Test")
 
 	// 3. Obtener por documento
-	reqBuscar := httptest.NewRequest("GET", "/personas/999", nil)
-	reqBuscar = mux.SetURLVars(reqBuscar, map[string]string{"documento": "999"})
+	reqBuscar := httptest.NewRequest("GET", "/api/v1/personas/999", nil)
 	resBuscar := httptest.NewRecorder()
 	router.ServeHTTP(resBuscar, reqBuscar)
 
 	assert.Equal(t, http.StatusOK, resBuscar.Code)
+	var encontrada models.Persona
+	assert.NoError(t, json.Unmarshal(resBuscar.Body.Bytes(), &encontrada))
+	etag := resBuscar.Header().Get("ETag")
+	assert.Equal(t, `"`+encontrada.ID.Hex()+`-1"`, etag)
+
+	// Si el cliente ya tiene la versión no se vuelve a enviar
+	reqCondicional := httptest.NewRequest("GET", "/api/v1/personas/999", nil)
+	reqCondicional.Header.Set("If-None-Match", etag)
+	resCondicional := httptest.NewRecorder()
+	router.ServeHTTP(resCondicional, reqCondicional)
+
+	assert.Equal(t, http.StatusNotModified, resCondicional.Code)
 
 	// 4. Modificar persona
 	persona.Nombre = "Actualizado"
 	bodyUpdate, _ := json.Marshal(persona)
-	reqUpdate := httptest.NewRequest("PUT", "/personas/999", bytes.NewReader(bodyUpdate))
-	reqUpdate = mux.SetURLVars(reqUpdate, map[string]string{"documento": "999"})
+	reqUpdate := httptest.NewRequest("PUT", "/api/v1/personas/999", bytes.NewReader(bodyUpdate))
+	reqUpdate.Header.Set("If-Match", etag)
 	resUpdate := httptest.NewRecorder()
 	router.ServeHTTP(resUpdate, reqUpdate)
 
 	assert.Equal(t, http.StatusOK, resUpdate.Code)
+	assert.Equal(t, `"`+encontrada.ID.Hex()+`-2"`, resUpdate.Header().Get("ETag"))
+
+	// La versión leída antes de modificar ya no sirve para eliminar
+	reqConflicto := httptest.NewRequest("DELETE", "/api/v1/personas/999", nil)
+	reqConflicto.Header.Set("If-Match", etag)
+	resConflicto := httptest.NewRecorder()
+	router.ServeHTTP(resConflicto, reqConflicto)
+
+	assert.Equal(t, http.StatusPreconditionFailed, resConflicto.Code)
 
 	// 5. Eliminar
-	reqDelete := httptest.NewRequest("DELETE", "/personas/999", nil)
-	reqDelete = mux.SetURLVars(reqDelete, map[string]string{"documento": "999"})
+	reqDelete := httptest.NewRequest("DELETE", "/api/v1/personas/999", nil)
+	reqDelete.Header.Set("If-Match", resUpdate.Header().Get("ETag"))
 	resDelete := httptest.NewRecorder()
 	router.ServeHTTP(resDelete, reqDelete)
 
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/contexto"
+	"github.com/danysoftdev/microservicio-go-mongodb/middlewares"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+)
+
+const tipoProblemaJSON = "application/problem+json"
+
+// estadoDeError traduce los errores de dominio del servicio a su código HTTP
+func estadoDeError(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrDuplicate):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrValidation), errors.Is(err, services.ErrImmutableField):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, services.ErrPreconditionRequired):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, services.ErrLoteDemasiadoGrande):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrLoteNoAplicado):
+		return http.StatusFailedDependency
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// erroresDeCampo extrae los campos rechazados de los errores de validación y de consulta
+func erroresDeCampo(err error) []models.ErrorCampo {
+	var validaciones services.ValidationErrors
+	if errors.As(err, &validaciones) {
+		errores := make([]models.ErrorCampo, len(validaciones))
+		for i, v := range validaciones {
+			errores[i] = models.ErrorCampo{Campo: v.Campo, Regla: v.Regla, Mensaje: v.Mensaje}
+		}
+		return errores
+	}
+
+	var validacion services.ValidationError
+	if errors.As(err, &validacion) {
+		return []models.ErrorCampo{{Campo: validacion.Campo, Regla: validacion.Regla, Mensaje: validacion.Mensaje}}
+	}
+
+	var consulta services.QueryError
+	if errors.As(err, &consulta) {
+		return []models.ErrorCampo{{Campo: consulta.Parametro, Mensaje: consulta.Mensaje}}
+	}
+
+	return nil
+}
+
+// escribirError responde con el problema que corresponde al error. Los errores internos
+// se registran en el log y al cliente solo se le envía el mensaje genérico
+func escribirError(w http.ResponseWriter, r *http.Request, err error, mensajeInterno string) {
+	estado := estadoDeError(err)
+	if estado == http.StatusInternalServerError {
+		log.Printf("❌ %s [%s]: %v", mensajeInterno, requestID(r), err)
+		escribirProblema(w, r, estado, mensajeInterno, nil)
+		return
+	}
+
+	escribirProblema(w, r, estado, err.Error(), erroresDeCampo(err))
+}
+
+// escribirProblema envía una respuesta application/problem+json
+func escribirProblema(w http.ResponseWriter, r *http.Request, estado int, detalle string, errores []models.ErrorCampo) {
+	problema := models.Problema{
+		Tipo:      "about:blank",
+		Titulo:    http.StatusText(estado),
+		Estado:    estado,
+		Detalle:   detalle,
+		Instancia: r.URL.Path,
+		RequestID: requestID(r),
+		Errores:   errores,
+	}
+
+	w.Header().Set("Content-Type", tipoProblemaJSON)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(estado)
+	json.NewEncoder(w).Encode(problema)
+}
+
+// requestID toma el identificador que dejó el middleware o, si el handler se llama
+// directamente, el que venga en la cabecera de la petición
+func requestID(r *http.Request) string {
+	if id := contexto.RequestID(r.Context()); id != "" {
+		return id
+	}
+	return r.Header.Get(middlewares.CabeceraRequestID)
+}
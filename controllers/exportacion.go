@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+)
+
+// parametrosExportacion son los parámetros de consulta que acepta la exportación
+var parametrosExportacion = append([]string{"format", "incluir_eliminados"}, parametrosFiltro...)
+
+// columnasExportacion son las columnas del CSV exportado, en orden
+var columnasExportacion = []string{
+	"id", "tipo_documento", "documento", "nombre", "apellido", "edad", "correo", "telefono", "direccion",
+	"version", "creado_en", "creado_por", "actualizado_en", "actualizado_por", "eliminado_en",
+}
+
+// exportador escribe las personas en un formato a medida que llegan del cursor
+type exportador interface {
+	iniciar() error
+	escribir(p models.Persona) error
+	terminar() error
+}
+
+// formatoExportacion describe cómo se entrega cada formato
+type formatoExportacion struct {
+	tipoContenido string
+	nuevo         func(w io.Writer) exportador
+}
+
+var formatosExportacion = map[string]formatoExportacion{
+	"csv":    {"text/csv; charset=utf-8", func(w io.Writer) exportador { return &exportadorCSV{escritor: csv.NewWriter(w)} }},
+	"ndjson": {"application/x-ndjson", func(w io.Writer) exportador { return &exportadorNDJSON{codificador: json.NewEncoder(w)} }},
+	"json":   {"application/json", func(w io.Writer) exportador { return &exportadorJSON{w: w} }},
+}
+
+// ExportarPersonas descarga todas las personas que cumplen los filtros del listado en
+// CSV, NDJSON o un arreglo JSON (por defecto). Las personas se escriben a medida que
+// se leen del cursor, así que la memoria usada no depende del tamaño del registro
+func ExportarPersonas(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if err := parametrosPermitidos(q, parametrosExportacion); err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+
+	nombreFormato := q.Get("format")
+	if nombreFormato == "" {
+		nombreFormato = "json"
+	}
+	formato, ok := formatosExportacion[nombreFormato]
+	if !ok {
+		escribirError(w, r, services.QueryError{Parametro: "format", Mensaje: "el formato debe ser csv, ndjson o json"}, "Parámetros inválidos")
+		return
+	}
+
+	filtro, err := leerFiltro(q)
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+	if filtro.IncluirEliminados, err = leerBooleano(q, "incluir_eliminados"); err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+
+	// La cabecera se envía con la primera persona, o al final si no hay ninguna, de modo
+	// que un filtro inválido o una falla al abrir el cursor todavía se puedan responder
+	salida := formato.nuevo(w)
+	iniciado := false
+	iniciar := func() error {
+		if iniciado {
+			return nil
+		}
+		iniciado = true
+		sinPlazos(w)
+		w.Header().Set("Content-Type", formato.tipoContenido)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="personas.%s"`, nombreFormato))
+		w.WriteHeader(http.StatusOK)
+		return salida.iniciar()
+	}
+
+	err = services.ExportarPersonas(r.Context(), filtro, func(p models.Persona) error {
+		if err := iniciar(); err != nil {
+			return err
+		}
+		return salida.escribir(p)
+	})
+	if err == nil {
+		if err = iniciar(); err == nil {
+			err = salida.terminar()
+		}
+	}
+	if err == nil {
+		return
+	}
+
+	if !iniciado {
+		escribirError(w, r, err, "Error al exportar personas")
+		return
+	}
+	// El estado y parte del archivo ya se enviaron: se corta la conexión para que el
+	// cliente no tome el archivo incompleto por uno completo
+	log.Printf("❌ Error al exportar personas [%s]: %v", requestID(r), err)
+	panic(http.ErrAbortHandler)
+}
+
+type exportadorCSV struct {
+	escritor *csv.Writer
+}
+
+func (e *exportadorCSV) iniciar() error {
+	return e.escritor.Write(columnasExportacion)
+}
+
+func (e *exportadorCSV) escribir(p models.Persona) error {
+	return e.escritor.Write([]string{
+		p.ID.Hex(), string(p.TipoDocumento), p.Documento, textoCSV(p.Nombre), textoCSV(p.Apellido), strconv.Itoa(p.Edad),
+		textoCSV(p.Correo), p.Telefono, textoCSV(p.Direccion), strconv.FormatInt(p.Version, 10),
+		fechaCSV(p.CreadoEn), textoCSV(p.CreadoPor), fechaCSV(p.ActualizadoEn), textoCSV(p.ActualizadoPor), fechaCSV(p.EliminadoEn),
+	})
+}
+
+// textoCSV antepone un apóstrofo a los textos libres que una hoja de cálculo tomaría
+// como fórmula. El teléfono no pasa por aquí: se guarda validado en formato E.164 y
+// su + inicial no es una fórmula
+func textoCSV(texto string) string {
+	if texto != "" && strings.ContainsRune("=+-@\t\r", rune(texto[0])) {
+		return "'" + texto
+	}
+	return texto
+}
+
+func (e *exportadorCSV) terminar() error {
+	e.escritor.Flush()
+	return e.escritor.Error()
+}
+
+// fechaCSV deja vacías las fechas que no se han asignado
+func fechaCSV(fecha time.Time) string {
+	if fecha.IsZero() {
+		return ""
+	}
+	return fecha.UTC().Format(time.RFC3339Nano)
+}
+
+// exportadorNDJSON escribe una persona por línea
+type exportadorNDJSON struct {
+	codificador *json.Encoder
+}
+
+func (e *exportadorNDJSON) iniciar() error { return nil }
+
+func (e *exportadorNDJSON) escribir(p models.Persona) error {
+	return e.codificador.Encode(p)
+}
+
+func (e *exportadorNDJSON) terminar() error { return nil }
+
+// exportadorJSON escribe un único arreglo, persona por persona
+type exportadorJSON struct {
+	w        io.Writer
+	escritas int
+}
+
+func (e *exportadorJSON) iniciar() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *exportadorJSON) escribir(p models.Persona) error {
+	datos, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if e.escritas > 0 {
+		datos = append([]byte{','}, datos...)
+	}
+	e.escritas++
+	_, err = e.w.Write(datos)
+	return err
+}
+
+func (e *exportadorJSON) terminar() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
@@ -0,0 +1,142 @@
+package controllers_test
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func personasExportadas() []models.Persona {
+	return []models.Persona{
+		{ID: primitive.NewObjectID(), TipoDocumento: models.CC, Documento: "123", Nombre: "Juan", Apellido: "Pérez, Gómez", Edad: 30, Version: 1, CreadoEn: instante},
+		{ID: primitive.NewObjectID(), TipoDocumento: models.TI, Documento: "456", Nombre: "Ana", Edad: 15, Version: 2, EliminadoEn: instante},
+	}
+}
+
+func nuevoRepoExportacion(filtro any, personas []models.Persona, err error) *mocks.MockPersonaRepo {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("RecorrerPersonas", mock.Anything, filtro).Return(personas, err)
+	return mockRepo
+}
+
+func TestExportarPersonasController_CSV(t *testing.T) {
+	personas := personasExportadas()
+	edadMin := 10
+	mockRepo := nuevoRepoExportacion(models.FiltroPersonas{EdadMin: &edadMin, IncluirEliminados: true}, personas, nil)
+
+	rr := httptest.NewRecorder()
+	controllers.ExportarPersonas(rr, httptest.NewRequest("GET", "/personas/export?format=csv&edad_min=10&incluir_eliminados=true", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="personas.csv"`, rr.Header().Get("Content-Disposition"))
+
+	filas, err := csv.NewReader(rr.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, filas, 3)
+	assert.Equal(t, "id", filas[0][0])
+	assert.Equal(t, []string{personas[0].ID.Hex(), "CC", "123", "Juan", "Pérez, Gómez", "30", "", "", "", "1", "2024-03-15T10:30:00Z", "", "", "", ""}, filas[1])
+	assert.Equal(t, "2024-03-15T10:30:00Z", filas[2][14])
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExportarPersonasController_CSVNoEjecutaFormulas(t *testing.T) {
+	persona := models.Persona{
+		ID: primitive.NewObjectID(), TipoDocumento: models.CC, Documento: "123",
+		Nombre: "=HYPERLINK(\"http://example.com\")", Apellido: "-2+3", Correo: "@sum(1)", Telefono: "+573001234567",
+		Direccion: "\tCalle 1", CreadoPor: "+cmd", ActualizadoPor: "\r1", Version: 1,
+	}
+	nuevoRepoExportacion(mock.Anything, []models.Persona{persona}, nil)
+
+	rr := httptest.NewRecorder()
+	controllers.ExportarPersonas(rr, httptest.NewRequest("GET", "/personas/export?format=csv", nil))
+
+	filas, err := csv.NewReader(rr.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, `'=HYPERLINK("http://example.com")`, filas[1][3])
+	assert.Equal(t, "'-2+3", filas[1][4])
+	assert.Equal(t, "'@sum(1)", filas[1][6])
+	assert.Equal(t, "+573001234567", filas[1][7])
+	assert.Equal(t, "'\tCalle 1", filas[1][8])
+	assert.Equal(t, "'+cmd", filas[1][11])
+	assert.Equal(t, "'\r1", filas[1][13])
+}
+
+func TestExportarPersonasController_NDJSON(t *testing.T) {
+	nuevoRepoExportacion(mock.Anything, personasExportadas(), nil)
+
+	rr := httptest.NewRecorder()
+	controllers.ExportarPersonas(rr, httptest.NewRequest("GET", "/personas/export?format=ndjson", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+
+	var documentos []string
+	lineas := bufio.NewScanner(rr.Body)
+	for lineas.Scan() {
+		var p models.Persona
+		assert.NoError(t, json.Unmarshal(lineas.Bytes(), &p))
+		documentos = append(documentos, p.Documento)
+	}
+	assert.Equal(t, []string{"123", "456"}, documentos)
+}
+
+func TestExportarPersonasController_JSON(t *testing.T) {
+	t.Run("Debe escribir un arreglo con todas las personas", func(t *testing.T) {
+		nuevoRepoExportacion(mock.Anything, personasExportadas(), nil)
+
+		rr := httptest.NewRecorder()
+		controllers.ExportarPersonas(rr, httptest.NewRequest("GET", "/personas/export", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `attachment; filename="personas.json"`, rr.Header().Get("Content-Disposition"))
+		var personas []models.Persona
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &personas))
+		assert.Len(t, personas, 2)
+	})
+
+	t.Run("Sin personas debe escribir un arreglo vacío", func(t *testing.T) {
+		nuevoRepoExportacion(mock.Anything, []models.Persona{}, nil)
+
+		rr := httptest.NewRecorder()
+		controllers.ExportarPersonas(rr, httptest.NewRequest("GET", "/personas/export?format=json", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, "[]", rr.Body.String())
+	})
+}
+
+func TestExportarPersonasController_Errores(t *testing.T) {
+	t.Run("Debe rechazar parámetros inválidos", func(t *testing.T) {
+		for _, ruta := range []string{"/personas/export?format=xlsx", "/personas/export?page=2", "/personas/export?edad_min=-1"} {
+			nuevoRepoExportacion(mock.Anything, []models.Persona{}, nil)
+			rr := httptest.NewRecorder()
+			controllers.ExportarPersonas(rr, httptest.NewRequest("GET", ruta, nil))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code, ruta)
+		}
+	})
+
+	t.Run("Una falla antes de la primera persona debe responder 500", func(t *testing.T) {
+		nuevoRepoExportacion(mock.Anything, []models.Persona{}, errors.New("servidor no disponible"))
+
+		rr := httptest.NewRecorder()
+		controllers.ExportarPersonas(rr, httptest.NewRequest("GET", "/personas/export?format=csv", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Empty(t, rr.Header().Get("Content-Disposition"))
+	})
+}
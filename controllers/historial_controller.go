@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+)
+
+func ObtenerHistorial(w http.ResponseWriter, r *http.Request) {
+	tipo, documento, err := leerIdentidad(r)
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+
+	cambios, err := services.HistorialPersona(r.Context(), tipo, documento)
+	if err != nil {
+		escribirError(w, r, err, "Error al obtener el historial")
+		return
+	}
+
+	escribirJSON(w, http.StatusOK, cambios)
+}
+
+// ObtenerPersonaEnFecha responde la persona tal como estaba en la fecha RFC 3339 del parámetro fecha
+func ObtenerPersonaEnFecha(w http.ResponseWriter, r *http.Request) {
+	tipo, documento, err := leerIdentidad(r)
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+
+	fecha, err := time.Parse(time.RFC3339Nano, r.URL.Query().Get("fecha"))
+	if err != nil {
+		escribirError(w, r, services.QueryError{Parametro: "fecha", Mensaje: "la fecha debe tener formato RFC 3339, por ejemplo 2024-03-15T10:30:00Z"}, "Parámetros inválidos")
+		return
+	}
+
+	persona, err := services.PersonaEnFecha(r.Context(), tipo, documento, fecha)
+	if err != nil {
+		escribirError(w, r, err, "Error al reconstruir la persona")
+		return
+	}
+
+	escribirJSON(w, http.StatusOK, persona)
+}
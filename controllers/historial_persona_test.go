@@ -0,0 +1,130 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var historialDePrueba = []models.CambioPersona{
+	{
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Operacion:     models.OperacionCrear,
+		Version:       1,
+		Actor:         "ana",
+		Fecha:         instante.Add(-time.Hour),
+		Cambios: map[string]models.CambioCampo{
+			"tipo_documento": {Despues: "CC"},
+			"documento":      {Despues: "123"},
+			"nombre":         {Despues: "Juan"},
+		},
+	},
+	{
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Operacion:     models.OperacionActualizar,
+		Version:       2,
+		Actor:         "luis",
+		Fecha:         instante,
+		Cambios: map[string]models.CambioCampo{
+			"nombre": {Antes: "Juan", Despues: "Juan Carlos"},
+		},
+	},
+}
+
+func nuevoHistorial(t *testing.T) *mocks.MockHistorialRepo {
+	mockHistorial := new(mocks.MockHistorialRepo)
+	services.SetHistorialRepository(mockHistorial)
+	t.Cleanup(func() { services.SetHistorialRepository(nil) })
+	return mockHistorial
+}
+
+func TestObtenerHistorialController_Success(t *testing.T) {
+	nuevoHistorial(t).On("ObtenerHistorial", mock.Anything, models.CC, "123").Return(historialDePrueba, nil)
+
+	req := httptest.NewRequest("GET", "/personas/123/historial", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.ObtenerHistorial(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var cambios []models.CambioPersona
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &cambios))
+	assert.Len(t, cambios, 2)
+	assert.Equal(t, "Juan", cambios[1].Cambios["nombre"].Antes)
+	assert.Equal(t, "Juan Carlos", cambios[1].Cambios["nombre"].Despues)
+}
+
+func TestObtenerHistorialController_NotFound(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "999").Return(models.Persona{}, mongo.ErrNoDocuments)
+	mockRepo.On("ObtenerPersonaEliminada", mock.Anything, models.CC, "999").Return(models.Persona{}, mongo.ErrNoDocuments)
+	nuevoHistorial(t).On("ObtenerHistorial", mock.Anything, models.CC, "999").Return([]models.CambioPersona{}, nil)
+
+	req := httptest.NewRequest("GET", "/personas/999/historial", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "999"})
+	rr := httptest.NewRecorder()
+
+	controllers.ObtenerHistorial(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestObtenerHistorialController_SinCambios(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{TipoDocumento: models.CC, Documento: "123"}, nil)
+	nuevoHistorial(t).On("ObtenerHistorial", mock.Anything, models.CC, "123").Return([]models.CambioPersona{}, nil)
+
+	req := httptest.NewRequest("GET", "/personas/123/historial", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.ObtenerHistorial(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, "[]", rr.Body.String())
+}
+
+func TestObtenerPersonaEnFechaController_Success(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{TipoDocumento: models.CC, Documento: "123", Nombre: "Juan Carlos"}, nil)
+	nuevoHistorial(t).On("ObtenerHistorial", mock.Anything, models.CC, "123").Return(historialDePrueba, nil)
+
+	req := httptest.NewRequest("GET", "/personas/123/historial/estado?fecha=2024-03-15T10:00:00Z", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.ObtenerPersonaEnFecha(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"nombre":"Juan"`)
+}
+
+func TestObtenerPersonaEnFechaController_FechaInvalida(t *testing.T) {
+	for _, fecha := range []string{"", "15/03/2024"} {
+		req := httptest.NewRequest("GET", "/personas/123/historial/estado?fecha="+fecha, nil)
+		req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+		rr := httptest.NewRecorder()
+
+		controllers.ObtenerPersonaEnFecha(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, fecha)
+		assert.Contains(t, rr.Body.String(), "fecha", fecha)
+	}
+}
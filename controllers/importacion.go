@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+)
+
+// columnasImportacion son las columnas que admite el CSV de importación. Todas son
+// obligatorias salvo tipo_documento, que por defecto es CC
+var columnasImportacion = []string{"tipo_documento", "documento", "nombre", "apellido", "edad", "correo", "telefono", "direccion"}
+
+// tamanoBloqueImportacion es la cantidad de filas que se guardan juntas. Solo se
+// mantiene en memoria un bloque a la vez, sin importar el tamaño del archivo
+const tamanoBloqueImportacion = 500
+
+// filaImportada es una persona leída del CSV junto con la línea donde empieza
+type filaImportada struct {
+	linea   int
+	persona models.Persona
+}
+
+// ImportarPersonas crea personas a partir de un CSV con encabezado. Con mode=upsert
+// las personas que ya existen se actualizan y con mode=skip (por defecto) se omiten.
+// Si el cliente acepta text/csv el reporte de errores se descarga como CSV
+func ImportarPersonas(w http.ResponseWriter, r *http.Request) {
+	modo := services.ModoImportacion(r.URL.Query().Get("mode"))
+	if modo == "" {
+		modo = services.ModoOmitir
+	}
+	if !modo.Valido() {
+		escribirError(w, r, services.QueryError{Parametro: "mode", Mensaje: "el modo debe ser upsert o skip"}, "Parámetros inválidos")
+		return
+	}
+
+	tipoContenido, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || tipoContenido != "text/csv" {
+		escribirProblema(w, r, http.StatusUnsupportedMediaType, "El cuerpo debe ser un CSV (text/csv)", nil)
+		return
+	}
+
+	sinPlazos(w)
+	lector := csv.NewReader(r.Body)
+	lector.TrimLeadingSpace = true
+
+	columnas, errores := leerEncabezado(lector)
+	if len(errores) > 0 {
+		escribirProblema(w, r, http.StatusBadRequest, "El encabezado del CSV es inválido", errores)
+		return
+	}
+
+	reporte := models.ReporteImportacion{Errores: []models.ErrorImportacion{}}
+	bloque := make([]filaImportada, 0, tamanoBloqueImportacion)
+
+	guardar := func() error {
+		if len(bloque) == 0 {
+			return nil
+		}
+		personas := make([]models.Persona, len(bloque))
+		for i, fila := range bloque {
+			personas[i] = fila.persona
+		}
+
+		resultados, err := services.ImportarPersonas(r.Context(), personas, modo)
+		if err != nil {
+			return err
+		}
+		for i, resultado := range resultados {
+			registrarFila(r, &reporte, bloque[i], resultado)
+		}
+		bloque = bloque[:0]
+		return nil
+	}
+
+	for {
+		registro, err := lector.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var errCSV *csv.ParseError
+		if errors.As(err, &errCSV) {
+			reporte.Fallidas++
+			reporte.Errores = append(reporte.Errores, models.ErrorImportacion{Linea: errCSV.StartLine, Estado: services.FilaFallida, Motivo: motivoCSV(errCSV)})
+			continue
+		}
+		if err != nil {
+			escribirError(w, r, err, "Error al leer el CSV")
+			return
+		}
+
+		linea, _ := lector.FieldPos(0)
+		persona, err := personaDeFila(columnas, registro)
+		if err != nil {
+			reporte.Fallidas++
+			reporte.Errores = append(reporte.Errores, models.ErrorImportacion{Linea: linea, Documento: persona.Documento, Estado: services.FilaFallida, Motivo: err.Error()})
+			continue
+		}
+
+		bloque = append(bloque, filaImportada{linea: linea, persona: persona})
+		if len(bloque) == tamanoBloqueImportacion {
+			if err := guardar(); err != nil {
+				escribirError(w, r, err, "Error al importar las personas")
+				return
+			}
+		}
+	}
+	if err := guardar(); err != nil {
+		escribirError(w, r, err, "Error al importar las personas")
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		escribirReporteCSV(w, reporte)
+		return
+	}
+	escribirJSON(w, http.StatusOK, reporte)
+}
+
+// leerEncabezado lee la primera fila y devuelve el campo de cada columna, o los
+// problemas del encabezado: columnas desconocidas, repetidas o faltantes
+func leerEncabezado(lector *csv.Reader) ([]string, []models.ErrorCampo) {
+	encabezado, err := lector.Read()
+	if err != nil {
+		return nil, []models.ErrorCampo{{Campo: "encabezado", Mensaje: "el archivo debe empezar con una fila de encabezado"}}
+	}
+
+	var errores []models.ErrorCampo
+	columnas := make([]string, len(encabezado))
+	for i, nombre := range encabezado {
+		// Las hojas de cálculo suelen anteponer un BOM de UTF-8 al archivo
+		if i == 0 {
+			nombre = strings.TrimPrefix(nombre, "\ufeff")
+		}
+		nombre = strings.ToLower(strings.TrimSpace(nombre))
+
+		switch {
+		case !slices.Contains(columnasImportacion, nombre):
+			errores = append(errores, models.ErrorCampo{Campo: nombre, Mensaje: fmt.Sprintf("la columna %q no corresponde a ningún campo", nombre)})
+		case slices.Contains(columnas[:i], nombre):
+			errores = append(errores, models.ErrorCampo{Campo: nombre, Mensaje: fmt.Sprintf("la columna %s está repetida", nombre)})
+		}
+		columnas[i] = nombre
+	}
+	for _, columna := range columnasImportacion[1:] {
+		if !slices.Contains(columnas, columna) {
+			errores = append(errores, models.ErrorCampo{Campo: columna, Regla: services.ReglaRequerido, Mensaje: fmt.Sprintf("falta la columna %s", columna)})
+		}
+	}
+	return columnas, errores
+}
+
+// personaDeFila arma la persona con los valores de cada columna
+func personaDeFila(columnas []string, registro []string) (models.Persona, error) {
+	var p models.Persona
+	var errEdad error
+
+	for i, valor := range registro {
+		switch columnas[i] {
+		case "tipo_documento":
+			p.TipoDocumento = models.TipoDocumento(valor)
+		case "documento":
+			p.Documento = valor
+		case "nombre":
+			p.Nombre = valor
+		case "apellido":
+			p.Apellido = valor
+		case "edad":
+			if p.Edad, errEdad = strconv.Atoi(strings.TrimSpace(valor)); errEdad != nil {
+				errEdad = errors.New("la edad debe ser un número entero")
+			}
+		case "correo":
+			p.Correo = valor
+		case "telefono":
+			p.Telefono = valor
+		case "direccion":
+			p.Direccion = valor
+		}
+	}
+	return p, errEdad
+}
+
+// motivoCSV explica por qué no se pudo leer una fila
+func motivoCSV(err *csv.ParseError) string {
+	if errors.Is(err, csv.ErrFieldCount) {
+		return "la fila no tiene la misma cantidad de columnas que el encabezado"
+	}
+	return fmt.Sprintf("la fila no es CSV válido: %v", err.Err)
+}
+
+// registrarFila suma el resultado de una fila al reporte. Las fallas internas se
+// registran en el log y en el reporte solo queda el mensaje genérico
+func registrarFila(r *http.Request, reporte *models.ReporteImportacion, fila filaImportada, resultado services.ResultadoImportacion) {
+	switch resultado.Estado {
+	case services.FilaCreada:
+		reporte.Creadas++
+		return
+	case services.FilaActualizada:
+		reporte.Actualizadas++
+		return
+	case services.FilaOmitida:
+		reporte.Omitidas++
+	default:
+		reporte.Fallidas++
+	}
+
+	motivo := resultado.Err.Error()
+	if estadoDeError(resultado.Err) == http.StatusInternalServerError {
+		log.Printf("❌ Error al importar la línea %d [%s]: %v", fila.linea, requestID(r), resultado.Err)
+		motivo = "Error al guardar la persona"
+	}
+	reporte.Errores = append(reporte.Errores, models.ErrorImportacion{
+		Linea:     fila.linea,
+		Documento: fila.persona.Documento,
+		Estado:    resultado.Estado,
+		Motivo:    motivo,
+	})
+}
+
+// escribirReporteCSV envía el reporte de errores como un CSV descargable. El resumen
+// va en las cabeceras, porque la importación no se puede repetir para consultarlo
+func escribirReporteCSV(w http.ResponseWriter, reporte models.ReporteImportacion) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="errores-importacion.csv"`)
+	w.Header().Set("X-Importacion-Creadas", strconv.Itoa(reporte.Creadas))
+	w.Header().Set("X-Importacion-Actualizadas", strconv.Itoa(reporte.Actualizadas))
+	w.Header().Set("X-Importacion-Omitidas", strconv.Itoa(reporte.Omitidas))
+	w.Header().Set("X-Importacion-Fallidas", strconv.Itoa(reporte.Fallidas))
+	w.WriteHeader(http.StatusOK)
+
+	escritor := csv.NewWriter(w)
+	escritor.Write([]string{"linea", "documento", "estado", "motivo"})
+	for _, e := range reporte.Errores {
+		escritor.Write([]string{strconv.Itoa(e.Linea), e.Documento, e.Estado, e.Motivo})
+	}
+	escritor.Flush()
+}
@@ -0,0 +1,92 @@
+package controllers_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const csvImportacion = "\ufeffDocumento,nombre,apellido,edad,correo,telefono,direccion\n" +
+	"123,Juan,Pérez,30,juan@example.com,3001234567,Calle 123\n" +
+	"456,Ana,Díaz,treinta,ana@example.com,3001234567,Calle 9\n" +
+	"789,Luis,Gómez,40,luis@example.com,3001234567\n" +
+	"\"321\",\"Eva\",\"Ruiz, Torres\",25,eva@example.com,3001234567,\"Calle 5\nApto 2\"\n" +
+	"654,Sara,López,22,correo,3001234567,Calle 1\n"
+
+func TestImportarPersonasController(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("InsertarPersonas", mock.Anything, mock.MatchedBy(func(personas []models.Persona) bool {
+		return len(personas) == 2 && personas[1].Apellido == "Ruiz, Torres"
+	}), false).Return(mongo.BulkWriteException{
+		WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 1, Code: 11000, Message: "E11000 duplicate key error"}}},
+	})
+
+	rr := httptest.NewRecorder()
+	controllers.ImportarPersonas(rr, nuevaPeticionLote("/personas/import", csvImportacion, "text/csv"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var reporte models.ReporteImportacion
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &reporte))
+	assert.Equal(t, 1, reporte.Creadas)
+	assert.Equal(t, 1, reporte.Omitidas)
+	assert.Equal(t, 3, reporte.Fallidas)
+
+	lineas := map[int]string{}
+	for _, e := range reporte.Errores {
+		lineas[e.Linea] = e.Estado
+	}
+	assert.Equal(t, map[int]string{3: services.FilaFallida, 4: services.FilaFallida, 5: services.FilaOmitida, 7: services.FilaFallida}, lineas)
+}
+
+func TestImportarPersonasController_ReporteCSV(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("InsertarPersonas", mock.Anything, mock.Anything, false).Return(nil)
+
+	req := nuevaPeticionLote("/personas/import?mode=upsert", csvImportacion, "text/csv; charset=utf-8")
+	req.Header.Set("Accept", "text/csv")
+	rr := httptest.NewRecorder()
+	controllers.ImportarPersonas(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), "attachment")
+	assert.Equal(t, "2", rr.Header().Get("X-Importacion-Creadas"))
+
+	filas, err := csv.NewReader(rr.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"linea", "documento", "estado", "motivo"}, filas[0])
+	assert.Equal(t, []string{"3", "456", "fallida", "la edad debe ser un número entero"}, filas[1])
+	assert.Len(t, filas, 4)
+}
+
+func TestImportarPersonasController_PeticionInvalida(t *testing.T) {
+	casos := []struct {
+		ruta, cuerpo, tipoContenido string
+		estado                      int
+	}{
+		{"/personas/import", csvImportacion, "application/json", http.StatusUnsupportedMediaType},
+		{"/personas/import?mode=replace", csvImportacion, "text/csv", http.StatusBadRequest},
+		{"/personas/import", "", "text/csv", http.StatusBadRequest},
+		{"/personas/import", "documento,nombre,ciudad\n123,Juan,Bogotá\n", "text/csv", http.StatusBadRequest},
+		{"/personas/import", strings.Replace(csvImportacion, "direccion", "nombre", 1), "text/csv", http.StatusBadRequest},
+	}
+
+	for _, caso := range casos {
+		rr := httptest.NewRecorder()
+		controllers.ImportarPersonas(rr, nuevaPeticionLote(caso.ruta, caso.cuerpo, caso.tipoContenido))
+
+		assert.Equal(t, caso.estado, rr.Code, caso.ruta)
+	}
+}
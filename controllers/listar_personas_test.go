@@ -12,40 +12,117 @@ import (
 	"github.com/danysoftdev/microservicio-go-mongodb/services"
 	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestObtenerPersonasController_Success(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.SetPersonaRepository(mockRepo)
 
-	mockData := []models.Persona{
-		{Documento: "1", Nombre: "Ana"},
-		{Documento: "2", Nombre: "Luis"},
+	mockData := models.PaginaPersonas{
+		Datos: []models.Persona{
+			{Documento: "1", Nombre: "Ana"},
+			{Documento: "2", Nombre: "Luis"},
+		},
+		Total:           5,
+		Pagina:          1,
+		Limite:          2,
+		SiguienteCursor: "65f1a2b3c4d5e6f708091a2b",
 	}
 
-	mockRepo.On("ObtenerPersonas").Return(mockData, nil)
+	mockRepo.On("ObtenerPersonasPaginadas", mock.Anything, models.ConsultaPersonas{Pagina: 1, Limite: 2, Orden: "edad", Descendente: true}).
+		Return(mockData, nil)
 
-	req := httptest.NewRequest(http.MethodGet, "/personas", nil)
+	req := httptest.NewRequest(http.MethodGet, "/personas?limit=2&sort=-edad", nil)
 	rr := httptest.NewRecorder()
 
 	controllers.ObtenerPersonas(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 
-	var respuesta []models.Persona
+	var respuesta models.PaginaPersonas
 	err := json.NewDecoder(rr.Body).Decode(&respuesta)
 	assert.NoError(t, err)
-	assert.Len(t, respuesta, 2)
-	assert.Equal(t, "Ana", respuesta[0].Nombre)
+	assert.Len(t, respuesta.Datos, 2)
+	assert.Equal(t, "Ana", respuesta.Datos[0].Nombre)
+	assert.Equal(t, int64(5), respuesta.Total)
+	assert.Equal(t, "65f1a2b3c4d5e6f708091a2b", respuesta.SiguienteCursor)
+	assert.Equal(t, "/personas?limit=2&page=2&sort=-edad", respuesta.Enlaces["next"])
+	assert.NotContains(t, respuesta.Enlaces, "prev")
 
 	mockRepo.AssertExpectations(t)
 }
 
+func TestObtenerPersonasController_Cursor(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	consulta := models.ConsultaPersonas{Pagina: 1, Limite: 1, Despues: "65f1a2b3c4d5e6f708091a2b"}
+	mockRepo.On("ObtenerPersonasPaginadas", mock.Anything, consulta).Return(models.PaginaPersonas{
+		Datos:           []models.Persona{{Documento: "3"}},
+		Total:           5,
+		Limite:          1,
+		SiguienteCursor: "65f1a2b3c4d5e6f708091a2c",
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/personas?limit=1&after=65f1a2b3c4d5e6f708091a2b", nil)
+	rr := httptest.NewRecorder()
+
+	controllers.ObtenerPersonas(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var respuesta models.PaginaPersonas
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&respuesta))
+	assert.Equal(t, "/personas?after=65f1a2b3c4d5e6f708091a2c&limit=1", respuesta.Enlaces["next"])
+	assert.Equal(t, "/personas?limit=1", respuesta.Enlaces["first"])
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestObtenerPersonasController_ParametrosInvalidos(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	casos := map[string]string{
+		"/personas?page=uno":     "el parámetro page debe ser un número entero",
+		"/personas?limit=1000":   "el límite debe estar entre 1 y 100",
+		"/personas?sort=correo":  "no se puede ordenar por el campo",
+		"/personas?after=abc123": "el cursor es inválido",
+	}
+
+	for ruta, mensaje := range casos {
+		req := httptest.NewRequest(http.MethodGet, ruta, nil)
+		rr := httptest.NewRecorder()
+
+		controllers.ObtenerPersonas(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, ruta)
+		assert.Contains(t, rr.Body.String(), mensaje, ruta)
+	}
+
+	mockRepo.AssertNotCalled(t, "ObtenerPersonasPaginadas", mock.Anything, mock.Anything)
+}
+
+func TestObtenerPersonasController_ProblemaConParametro(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/personas?edad_max=muchos", nil)
+	rr := httptest.NewRecorder()
+
+	controllers.ObtenerPersonas(rr, req)
+
+	var problema models.Problema
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&problema))
+	assert.Equal(t, http.StatusBadRequest, problema.Estado)
+	assert.Equal(t, "Bad Request", problema.Titulo)
+	assert.Equal(t, []models.ErrorCampo{{Campo: "edad_max", Mensaje: "el parámetro edad_max debe ser un número entero"}}, problema.Errores)
+}
+
 func TestObtenerPersonasController_Error(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.SetPersonaRepository(mockRepo)
 
-	mockRepo.On("ObtenerPersonas").Return([]models.Persona{}, errors.New("fallo inesperado"))
+	mockRepo.On("ObtenerPersonasPaginadas", mock.Anything, models.ConsultaPersonas{Pagina: 1, Limite: services.LimitePorDefecto}).
+		Return(models.PaginaPersonas{}, errors.New("fallo inesperado"))
 
 	req := httptest.NewRequest(http.MethodGet, "/personas", nil)
 	rr := httptest.NewRecorder()
@@ -56,4 +133,90 @@ func TestObtenerPersonasController_Error(t *testing.T) {
 	assert.Contains(t, rr.Body.String(), "Error al obtener personas")
 
 	mockRepo.AssertExpectations(t)
-}
\ No newline at end of file
+}
+
+func TestObtenerPersonasController_Filtros(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	min, max := 20, 40
+	consulta := models.ConsultaPersonas{Pagina: 1, Limite: services.LimitePorDefecto, Filtro: models.FiltroPersonas{
+		Apellido:          "Gómez",
+		DominioCorreo:     "example.com",
+		EdadMin:           &min,
+		EdadMax:           &max,
+		PrefijoTelefono:   "+57300",
+		Direccion:         "Calle",
+		IncluirEliminados: true,
+	}}
+	mockRepo.On("ObtenerPersonasPaginadas", mock.Anything, consulta).Return(models.PaginaPersonas{Datos: []models.Persona{}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/personas?apellido=G%C3%B3mez&correo_dominio=example.com&edad_min=20&edad_max=40&telefono_prefijo=300&direccion=Calle&incluir_eliminados=true", nil)
+	rr := httptest.NewRecorder()
+
+	controllers.ObtenerPersonas(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestObtenerPersonasController_FiltrosInvalidos(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	casos := map[string]string{
+		"/personas?ciudad=Cali":             "el parámetro ciudad no es un filtro válido",
+		"/personas?edad_min=veinte":         "el parámetro edad_min debe ser un número entero",
+		"/personas?edad_min=40&edad_max=20": "edad_min no puede ser mayor que edad_max",
+		"/personas?correo_dominio=a@b":      "el dominio de correo es inválido",
+		"/personas?incluir_eliminados=si":   "el parámetro incluir_eliminados debe ser true o false",
+	}
+
+	for ruta, mensaje := range casos {
+		req := httptest.NewRequest(http.MethodGet, ruta, nil)
+		rr := httptest.NewRecorder()
+
+		controllers.ObtenerPersonas(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, ruta)
+		assert.Contains(t, rr.Body.String(), mensaje, ruta)
+	}
+
+	mockRepo.AssertNotCalled(t, "ObtenerPersonasPaginadas", mock.Anything, mock.Anything)
+}
+
+func TestObtenerPersonasController_NoModificado(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonasPaginadas", mock.Anything, mock.Anything).
+		Return(models.PaginaPersonas{Datos: []models.Persona{{Documento: "1", Nombre: "Ana"}}, Total: 1, Limite: 20}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/personas", nil)
+	rr := httptest.NewRecorder()
+	controllers.ObtenerPersonas(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// Con la misma ETag el listado no se vuelve a enviar
+	req = httptest.NewRequest(http.MethodGet, "/personas", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	controllers.ObtenerPersonas(rr, req)
+
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+
+	// Otra página tiene otro contenido y por lo tanto otra ETag
+	req = httptest.NewRequest(http.MethodGet, "/personas?page=2", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	controllers.ObtenerPersonas(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+}
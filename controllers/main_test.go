@@ -0,0 +1,27 @@
+package controllers_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// instante es la hora fija con la que el servicio marca las escrituras durante las pruebas
+var instante = time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+
+// idPersona es el _id de la persona guardada en las pruebas que usan ETag
+var idPersona, _ = primitive.ObjectIDFromHex("65f4a1b2c3d4e5f601234567")
+
+// etag arma la ETag que el servicio asigna a la versión de una persona
+func etag(id primitive.ObjectID, version int64) string {
+	return fmt.Sprintf(`"%s-%d"`, id.Hex(), version)
+}
+
+func TestMain(m *testing.M) {
+	services.SetReloj(func() time.Time { return instante })
+	os.Exit(m.Run())
+}
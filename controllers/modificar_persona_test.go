@@ -14,6 +14,8 @@ import (
 	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func TestActualizarPersonaController_Success(t *testing.T) {
@@ -21,34 +23,50 @@ func TestActualizarPersonaController_Success(t *testing.T) {
 	services.SetPersonaRepository(mockRepo)
 
 	persona := models.Persona{
-		Documento: "123",
-		Nombre:    "Juan",
-		Apellido:  "Pérez",
-		Edad:      30,
-		Correo:    "juan@example.com",
-		Telefono:  "1234567890",
-		Direccion: "Calle Falsa 123",
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Juan",
+		Apellido:      "Pérez",
+		Edad:          30,
+		Correo:        "juan@example.com",
+		Telefono:      "+573001234567",
+		Direccion:     "Calle Falsa 123",
 	}
 
+	guardada := persona
+	guardada.ID = idPersona
+	guardada.Version = 1
+	actualizada := persona
+	actualizada.Version = 2
+	actualizada.ActualizadoEn = instante
+	actualizada.ActualizadoPor = services.UsuarioAnonimo
+
 	// Mock de verificación de existencia y luego actualización
-	mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(persona, nil)
-	mockRepo.On("ActualizarPersona", "123", persona).Return(nil)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(guardada, nil)
+	mockRepo.On("ActualizarPersona", mock.Anything, models.CC, "123", int64(1), actualizada).Return(nil)
 
 	body, _ := json.Marshal(persona)
 	req := httptest.NewRequest("PUT", "/personas/123", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", etag(idPersona, 1))
 	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
 	rr := httptest.NewRecorder()
 
 	controllers.ActualizarPersona(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
-	assert.Contains(t, rr.Body.String(), "actualizada exitosamente")
+	assert.Equal(t, "/api/v1/personas/123", rr.Header().Get("Location"))
+	assert.Equal(t, etag(idPersona, 2), rr.Header().Get("ETag"))
+	var respuesta models.Persona
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &respuesta))
+	actualizada.ID = idPersona
+	assert.Equal(t, actualizada, respuesta)
 
 	mockRepo.AssertExpectations(t)
 }
 
 func TestActualizarPersonaController_ErrorFormato(t *testing.T) {
 	req := httptest.NewRequest("PUT", "/personas/123", bytes.NewBuffer([]byte("invalido")))
+	req.Header.Set("If-Match", etag(idPersona, 1))
 	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
 	rr := httptest.NewRecorder()
 
@@ -63,27 +81,29 @@ func TestActualizarPersonaController_ErrorServicio(t *testing.T) {
 	services.SetPersonaRepository(mockRepo)
 
 	persona := models.Persona{
-		Documento: "123",
-		Nombre:    "Juan",
-		Apellido:  "Pérez",
-		Edad:      30,
-		Correo:    "juan@example.com",
-		Telefono:  "1234567890",
-		Direccion: "Calle Falsa 123",
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Juan",
+		Apellido:      "Pérez",
+		Edad:          30,
+		Correo:        "juan@example.com",
+		Telefono:      "+573001234567",
+		Direccion:     "Calle Falsa 123",
 	}
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(persona, nil)
-	mockRepo.On("ActualizarPersona", "123", persona).Return(errors.New("fallo actualización"))
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(persona, nil)
+	mockRepo.On("ActualizarPersona", mock.Anything, models.CC, "123", int64(0), mock.Anything).Return(errors.New("fallo actualización"))
 
 	body, _ := json.Marshal(persona)
 	req := httptest.NewRequest("PUT", "/personas/123", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", "*")
 	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
 	rr := httptest.NewRecorder()
 
 	controllers.ActualizarPersona(rr, req)
 
-	assert.Equal(t, http.StatusBadRequest, rr.Code)
-	assert.Contains(t, rr.Body.String(), "fallo actualización")
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Error al actualizar la persona")
 
 	mockRepo.AssertExpectations(t)
 }
@@ -98,20 +118,125 @@ func TestActualizarPersonaController_ErrorDocumentoDistinto(t *testing.T) {
 		Apellido:  "López",
 		Edad:      35,
 		Correo:    "maria@example.com",
-		Telefono:  "3214567890",
+		Telefono:  "+573214567890",
 		Direccion: "Carrera Falsa 456",
 	}
 
 	// Esto no se llega a ejecutar, pero por orden es buena práctica
-	mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(models.Persona{}, nil)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{}, nil)
 
 	body, _ := json.Marshal(persona)
 	req := httptest.NewRequest("PUT", "/personas/123", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", "*")
 	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
 	rr := httptest.NewRecorder()
 
 	controllers.ActualizarPersona(rr, req)
 
-	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
 	assert.Contains(t, rr.Body.String(), "no se puede modificar el documento")
 }
+
+func TestActualizarPersonaController_NoEncontrada(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	persona := models.Persona{
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Juan",
+		Apellido:      "Pérez",
+		Edad:          30,
+		Correo:        "juan@example.com",
+		Telefono:      "+573001234567",
+		Direccion:     "Calle Falsa 123",
+	}
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{}, mongo.ErrNoDocuments)
+
+	body, _ := json.Marshal(persona)
+	req := httptest.NewRequest("PUT", "/personas/123", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", etag(idPersona, 1))
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.ActualizarPersona(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "persona no encontrada")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestActualizarPersonaController_SinIfMatch(t *testing.T) {
+	req := httptest.NewRequest("PUT", "/personas/123", bytes.NewBufferString("{}"))
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.ActualizarPersona(rr, req)
+
+	assert.Equal(t, http.StatusPreconditionRequired, rr.Code)
+}
+
+func TestActualizarPersonaController_Conflicto(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	persona := models.Persona{
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Juan",
+		Apellido:      "Pérez",
+		Edad:          30,
+		Correo:        "juan@example.com",
+		Telefono:      "+573001234567",
+		Direccion:     "Calle Falsa 123",
+		Version:       3,
+	}
+
+	// Otro operador ya guardó la versión 3, el cliente todavía tiene la 2
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(persona, nil)
+
+	body, _ := json.Marshal(persona)
+	req := httptest.NewRequest("PUT", "/personas/123", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", etag(idPersona, 2))
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.ActualizarPersona(rr, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	assert.Contains(t, rr.Body.String(), "la persona fue modificada por otra operación")
+	mockRepo.AssertNotCalled(t, "ActualizarPersona", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestActualizarPersonaController_IgnoraEliminadoEn(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{
+		ID:            idPersona,
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Juan",
+		Apellido:      "Pérez",
+		Edad:          30,
+		Correo:        "juan@example.com",
+		Telefono:      "+573001234567",
+		Direccion:     "Calle Falsa 123",
+		Version:       1,
+	}, nil)
+	mockRepo.On("ActualizarPersona", mock.Anything, models.CC, "123", int64(1), mock.MatchedBy(func(p models.Persona) bool {
+		return p.EliminadoEn.IsZero()
+	})).Return(nil)
+
+	req := httptest.NewRequest("PUT", "/personas/123", bytes.NewBufferString(`{"tipo_documento": "CC", "documento": "123", "nombre": "Juan", "apellido": "Pérez", "edad": 30, "correo": "juan@example.com", "telefono": "3001234567", "direccion": "Calle Falsa 123", "eliminado_en": "2000-01-01T00:00:00Z"}`))
+	req.Header.Set("If-Match", etag(idPersona, 1))
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.ActualizarPersona(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "eliminado_en")
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+)
+
+// leerFiltroMasivo lee el filtro de una operación masiva y si se pide solo simularla
+// con dry_run=true. extras son los demás parámetros que admite la operación
+func leerFiltroMasivo(q url.Values, extras ...string) (models.FiltroPersonas, bool, error) {
+	permitidos := append([]string{"dry_run"}, parametrosFiltro...)
+	if err := parametrosPermitidos(q, append(permitidos, extras...)); err != nil {
+		return models.FiltroPersonas{}, false, err
+	}
+
+	filtro, err := leerFiltro(q)
+	if err != nil {
+		return filtro, false, err
+	}
+	simulacion, err := leerBooleano(q, "dry_run")
+	return filtro, simulacion, err
+}
+
+// ParchearPersonas aplica los mismos cambios a todas las personas del filtro. Como
+// la duración depende de cuántas personas lo cumplen, no tiene los tiempos máximos del servidor
+func ParchearPersonas(w http.ResponseWriter, r *http.Request) {
+	filtro, simulacion, err := leerFiltroMasivo(r.URL.Query())
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+
+	tipoContenido, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || tipoContenido != "application/json" {
+		escribirProblema(w, r, http.StatusUnsupportedMediaType, "El cuerpo debe ser JSON (application/json)", nil)
+		return
+	}
+
+	var actualizacion models.ActualizacionMasiva
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&actualizacion); err != nil {
+		escribirProblema(w, r, http.StatusBadRequest, "El formato del cuerpo es inválido", nil)
+		return
+	}
+
+	sinPlazos(w)
+	resultado, err := services.ParchearPersonas(r.Context(), filtro, actualizacion, simulacion)
+	if err != nil {
+		escribirError(w, r, err, "Error al actualizar las personas")
+		return
+	}
+
+	escribirJSON(w, http.StatusOK, resultado)
+}
+
+// EliminarPersonas elimina todas las personas del filtro. Con purgar=true borra
+// definitivamente las que ya estaban eliminadas. Igual que ParchearPersonas, no tiene
+// los tiempos máximos del servidor
+func EliminarPersonas(w http.ResponseWriter, r *http.Request) {
+	filtro, simulacion, err := leerFiltroMasivo(r.URL.Query(), "purgar")
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+	purgar, err := leerBooleano(r.URL.Query(), "purgar")
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+
+	sinPlazos(w)
+	var resultado models.ResultadoMasivo
+	if purgar {
+		resultado, err = services.PurgarPersonas(r.Context(), filtro, simulacion)
+	} else {
+		resultado, err = services.BorrarPersonas(r.Context(), filtro, simulacion)
+	}
+	if err != nil {
+		escribirError(w, r, err, "Error al eliminar las personas")
+		return
+	}
+
+	escribirJSON(w, http.StatusOK, resultado)
+}
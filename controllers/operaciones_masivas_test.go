@@ -0,0 +1,106 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func nuevoRepoMasivo(personas ...models.Persona) *mocks.MockPersonaRepo {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("RecorrerPersonas", mock.Anything, mock.Anything).Return(personas, nil)
+	return mockRepo
+}
+
+func TestParchearPersonasController_Simulacion(t *testing.T) {
+	persona := models.Persona{ID: primitive.NewObjectID(), TipoDocumento: models.CC, Documento: "123", Direccion: "Calle 1, Bogta", Version: 1}
+	mockRepo := nuevoRepoMasivo(persona)
+
+	cuerpo := `{"reemplazos": {"direccion": {"buscar": "Bogta", "reemplazo": "Bogotá"}}}`
+	req := httptest.NewRequest("PATCH", "/admin/personas?direccion=Bogta&dry_run=true", strings.NewReader(cuerpo))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	controllers.ParchearPersonas(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resultado models.ResultadoMasivo
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resultado))
+	assert.True(t, resultado.Simulacion)
+	assert.Equal(t, int64(1), resultado.Coincidentes)
+	assert.Equal(t, int64(1), resultado.Modificadas)
+	assert.Len(t, resultado.Muestra, 1)
+	mockRepo.AssertNotCalled(t, "ActualizarPersonas", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestParchearPersonasController_PeticionInvalida(t *testing.T) {
+	casos := []struct {
+		ruta, cuerpo, tipoContenido string
+		estado                      int
+	}{
+		{"/admin/personas?direccion=Bogta", `{"cambios": {"edad": 30}}`, "text/plain", http.StatusUnsupportedMediaType},
+		{"/admin/personas?direccion=Bogta", `{"cambio": {"edad": 30}}`, "application/json", http.StatusBadRequest},
+		{"/admin/personas?ciudad=Bogta", `{"cambios": {"edad": 30}}`, "application/json", http.StatusBadRequest},
+		{"/admin/personas", `{"cambios": {"edad": 30}}`, "application/json", http.StatusBadRequest},
+		{"/admin/personas?direccion=Bogta", `{"cambios": {"edad": -1}}`, "application/json", http.StatusUnprocessableEntity},
+	}
+
+	for _, caso := range casos {
+		req := httptest.NewRequest("PATCH", caso.ruta, strings.NewReader(caso.cuerpo))
+		req.Header.Set("Content-Type", caso.tipoContenido)
+		rr := httptest.NewRecorder()
+
+		controllers.ParchearPersonas(rr, req)
+
+		assert.Equal(t, caso.estado, rr.Code, caso.ruta+" "+caso.cuerpo)
+	}
+}
+
+func TestEliminarPersonasController(t *testing.T) {
+	activa := models.Persona{ID: primitive.NewObjectID(), Documento: "123", Correo: "a@example.com", Version: 1}
+	eliminada := models.Persona{ID: primitive.NewObjectID(), Documento: "456", Correo: "b@example.com", Version: 2, EliminadoEn: instante}
+
+	t.Run("Debe eliminar las personas del filtro", func(t *testing.T) {
+		mockRepo := nuevoRepoMasivo(activa)
+		mockRepo.On("ActualizarPersonas", mock.Anything, mock.Anything, map[primitive.ObjectID]int64{activa.ID: 1}, mock.Anything).Return([]primitive.ObjectID{activa.ID}, nil)
+
+		rr := httptest.NewRecorder()
+		controllers.EliminarPersonas(rr, httptest.NewRequest("DELETE", "/admin/personas?correo_dominio=example.com", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"modificadas":1`)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Con purgar debe borrar solo las eliminadas", func(t *testing.T) {
+		mockRepo := nuevoRepoMasivo(activa, eliminada)
+		mockRepo.On("PurgarPersonas", mock.Anything, mock.Anything, map[primitive.ObjectID]int64{eliminada.ID: 2}).Return([]primitive.ObjectID{eliminada.ID}, nil)
+
+		rr := httptest.NewRecorder()
+		controllers.EliminarPersonas(rr, httptest.NewRequest("DELETE", "/admin/personas?correo_dominio=example.com&purgar=true", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"coincidentes":1`)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Debe rechazar parámetros inválidos", func(t *testing.T) {
+		for _, ruta := range []string{"/admin/personas?correo_dominio=example.com&dry_run=quizas", "/admin/personas?correo_dominio=example.com&purgar=1x"} {
+			rr := httptest.NewRecorder()
+			controllers.EliminarPersonas(rr, httptest.NewRequest("DELETE", ruta, nil))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code, ruta)
+		}
+	})
+}
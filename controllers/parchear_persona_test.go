@@ -0,0 +1,124 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func nuevaPeticionParche(cuerpo, tipoContenido string) *http.Request {
+	req := httptest.NewRequest("PATCH", "/personas/123", strings.NewReader(cuerpo))
+	req.Header.Set("Content-Type", tipoContenido)
+	req.Header.Set("If-Match", etag(idPersona, 1))
+	return mux.SetURLVars(req, map[string]string{"documento": "123"})
+}
+
+func TestParchearPersonaController_Success(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	persona := models.Persona{
+		ID:            idPersona,
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Juan",
+		Apellido:      "Pérez",
+		Edad:          30,
+		Correo:        "juan@example.com",
+		Telefono:      "+573001234567",
+		Direccion:     "Calle Falsa 123",
+		Version:       1,
+	}
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(persona, nil)
+	mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123", int64(1), map[string]any{"telefono": "+573109998877", "version": int64(2), "actualizado_en": instante, "actualizado_por": services.UsuarioAnonimo}).Return(nil)
+
+	rr := httptest.NewRecorder()
+	controllers.ParchearPersona(rr, nuevaPeticionParche(`{"telefono": "3109998877"}`, "application/merge-patch+json"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, etag(idPersona, 2), rr.Header().Get("ETag"))
+	assert.Contains(t, rr.Body.String(), `"telefono":"+573109998877"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestParchearPersonaController_TipoContenidoNoSoportado(t *testing.T) {
+	rr := httptest.NewRecorder()
+	controllers.ParchearPersona(rr, nuevaPeticionParche(`[{"op": "replace"}]`, "application/json-patch+json"))
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	assert.Equal(t, "application/merge-patch+json, application/json", rr.Header().Get("Accept-Patch"))
+}
+
+func TestParchearPersonaController_ErrorFormato(t *testing.T) {
+	for _, cuerpo := range []string{"invalido", "null", `["nombre"]`} {
+		rr := httptest.NewRecorder()
+		controllers.ParchearPersona(rr, nuevaPeticionParche(cuerpo, "application/merge-patch+json"))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, cuerpo)
+	}
+}
+
+func TestParchearPersonaController_Validacion(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{
+		ID:            idPersona,
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Juan",
+		Apellido:      "Pérez",
+		Edad:          30,
+		Correo:        "juan@example.com",
+		Telefono:      "+573001234567",
+		Direccion:     "Calle Falsa 123",
+		Version:       1,
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	controllers.ParchearPersona(rr, nuevaPeticionParche(`{"correo": "juan", "apellido": null}`, "application/json; charset=utf-8"))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"field":"apellido"`)
+	assert.Contains(t, rr.Body.String(), `"field":"correo"`)
+	mockRepo.AssertNotCalled(t, "ActualizarCampos", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestParchearPersonaController_IgnoraEliminadoEn(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{
+		ID:            idPersona,
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Juan",
+		Apellido:      "Pérez",
+		Edad:          30,
+		Correo:        "juan@example.com",
+		Telefono:      "+573001234567",
+		Direccion:     "Calle Falsa 123",
+		Version:       1,
+	}, nil)
+	mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123", int64(1), map[string]any{
+		"nombre": "Juana", "version": int64(2), "actualizado_en": instante, "actualizado_por": services.UsuarioAnonimo,
+	}).Return(nil)
+
+	// Solo la eliminación puede marcar a la persona como eliminada
+	rr := httptest.NewRecorder()
+	controllers.ParchearPersona(rr, nuevaPeticionParche(`{"nombre": "Juana", "eliminado_en": "2000-01-01T00:00:00Z"}`, "application/merge-patch+json"))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "eliminado_en")
+	mockRepo.AssertExpectations(t)
+}
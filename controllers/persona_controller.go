@@ -2,7 +2,13 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/danysoftdev/microservicio-go-mongodb/models"
 	"github.com/danysoftdev/microservicio-go-mongodb/services"
@@ -15,72 +21,358 @@ func CrearPersona(w http.ResponseWriter, r *http.Request) {
 
 	err := json.NewDecoder(r.Body).Decode(&persona)
 	if err != nil {
-		http.Error(w, "El formato del cuerpo es inválido", http.StatusBadRequest)
+		escribirProblema(w, r, http.StatusBadRequest, "El formato del cuerpo es inválido", nil)
 		return
 	}
 
-	err = services.CrearPersona(persona)
+	creada, err := services.CrearPersona(r.Context(), persona)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		escribirError(w, r, err, "Error al crear la persona")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"mensaje": "Persona creada exitosamente"})
+	escribirPersona(w, http.StatusCreated, creada)
 }
 
 func ObtenerPersonas(w http.ResponseWriter, r *http.Request) {
-	personas, err := services.ListarPersonas()
+	consulta, err := leerConsulta(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Error al obtener personas", http.StatusInternalServerError)
+		escribirError(w, r, err, "Parámetros inválidos")
 		return
 	}
 
-	json.NewEncoder(w).Encode(personas)
+	pagina, err := services.ListarPersonas(r.Context(), consulta)
+	if err != nil {
+		escribirError(w, r, err, "Error al obtener personas")
+		return
+	}
+
+	pagina.Enlaces = enlacesPagina(r.URL, pagina)
+	escribirJSONCondicional(w, r, pagina)
+}
+
+// parametrosFiltro son los criterios por campo con los que se filtran las personas
+var parametrosFiltro = []string{"apellido", "correo_dominio", "edad_min", "edad_max", "telefono_prefijo", "direccion"}
+
+// parametrosListado son los parámetros de consulta que acepta el listado de personas
+var parametrosListado = append([]string{"page", "limit", "after", "sort", "incluir_eliminados"}, parametrosFiltro...)
+
+// leerConsulta convierte los parámetros de paginación, orden y filtrado en una consulta de personas.
+// El orden descendente se indica anteponiendo "-" al campo, por ejemplo sort=-edad
+func leerConsulta(q url.Values) (models.ConsultaPersonas, error) {
+	var consulta models.ConsultaPersonas
+
+	if err := parametrosPermitidos(q, parametrosListado); err != nil {
+		return consulta, err
+	}
+
+	pagina, err := leerEntero(q, "page")
+	if err != nil {
+		return consulta, err
+	}
+	limite, err := leerEntero(q, "limit")
+	if err != nil {
+		return consulta, err
+	}
+	if pagina != nil {
+		consulta.Pagina = *pagina
+	}
+	if limite != nil {
+		consulta.Limite = *limite
+	}
+
+	consulta.Despues = q.Get("after")
+	consulta.Orden = q.Get("sort")
+	if strings.HasPrefix(consulta.Orden, "-") {
+		consulta.Orden = strings.TrimPrefix(consulta.Orden, "-")
+		consulta.Descendente = true
+	}
+
+	if consulta.Filtro, err = leerFiltro(q); err != nil {
+		return consulta, err
+	}
+	if consulta.Filtro.IncluirEliminados, err = leerBooleano(q, "incluir_eliminados"); err != nil {
+		return consulta, err
+	}
+
+	return consulta, nil
+}
+
+// parametrosPermitidos rechaza cualquier parámetro de consulta que no esté en la lista
+func parametrosPermitidos(q url.Values, permitidos []string) error {
+	for clave := range q {
+		if !slices.Contains(permitidos, clave) {
+			return services.QueryError{Parametro: clave, Mensaje: fmt.Sprintf("el parámetro %s no es un filtro válido", clave)}
+		}
+	}
+	return nil
+}
+
+// leerFiltro convierte los criterios por campo de la consulta en un filtro de personas
+func leerFiltro(q url.Values) (models.FiltroPersonas, error) {
+	filtro := models.FiltroPersonas{
+		Apellido:        q.Get("apellido"),
+		DominioCorreo:   q.Get("correo_dominio"),
+		PrefijoTelefono: q.Get("telefono_prefijo"),
+		Direccion:       q.Get("direccion"),
+	}
+
+	var err error
+	if filtro.EdadMin, err = leerEntero(q, "edad_min"); err != nil {
+		return filtro, err
+	}
+	if filtro.EdadMax, err = leerEntero(q, "edad_max"); err != nil {
+		return filtro, err
+	}
+	return filtro, nil
+}
+
+// leerEntero devuelve nil si el parámetro no viene en la consulta
+func leerEntero(q url.Values, clave string) (*int, error) {
+	v := q.Get(clave)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, services.QueryError{Parametro: clave, Mensaje: fmt.Sprintf("el parámetro %s debe ser un número entero", clave)}
+	}
+	return &n, nil
+}
+
+// leerBooleano devuelve false si el parámetro no viene en la consulta
+func leerBooleano(q url.Values, clave string) (bool, error) {
+	v := q.Get(clave)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, services.QueryError{Parametro: clave, Mensaje: fmt.Sprintf("el parámetro %s debe ser true o false", clave)}
+	}
+	return b, nil
+}
+
+// enlacesPagina arma los enlaces de navegación del listado a partir de la URL recibida
+func enlacesPagina(u *url.URL, pagina models.PaginaPersonas) map[string]string {
+	enlace := func(cambios map[string]string) string {
+		q := u.Query()
+		for clave, valor := range cambios {
+			if valor == "" {
+				q.Del(clave)
+			} else {
+				q.Set(clave, valor)
+			}
+		}
+		destino := url.URL{Path: u.Path, RawQuery: q.Encode()}
+		return destino.String()
+	}
+
+	enlaces := map[string]string{
+		"self":  u.RequestURI(),
+		"first": enlace(map[string]string{"page": "", "after": ""}),
+	}
+
+	if pagina.SiguienteCursor != "" {
+		if pagina.Pagina == 0 {
+			enlaces["next"] = enlace(map[string]string{"after": pagina.SiguienteCursor})
+		} else {
+			enlaces["next"] = enlace(map[string]string{"page": strconv.Itoa(pagina.Pagina + 1)})
+		}
+	}
+	if pagina.Pagina > 1 {
+		enlaces["prev"] = enlace(map[string]string{"page": strconv.Itoa(pagina.Pagina - 1)})
+	}
+
+	return enlaces
+}
+
+func BuscarPersonas(w http.ResponseWriter, r *http.Request) {
+	limite, err := leerEntero(r.URL.Query(), "limit")
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+	if limite == nil {
+		limite = new(int)
+	}
+
+	personas, err := services.BuscarPersonas(r.Context(), r.URL.Query().Get("q"), *limite)
+	if err != nil {
+		escribirError(w, r, err, "Error al buscar personas")
+		return
+	}
+
+	escribirJSON(w, http.StatusOK, personas)
+}
+
+// leerIdentidad toma el documento de la ruta y el tipo del parámetro tipo_documento,
+// que por compatibilidad se asume CC cuando no se envía
+func leerIdentidad(r *http.Request) (models.TipoDocumento, string, error) {
+	documento := mux.Vars(r)["documento"]
+
+	tipo := services.NormalizarTipoDocumento(models.TipoDocumento(r.URL.Query().Get("tipo_documento")))
+	if !tipo.Valido() {
+		return tipo, documento, services.QueryError{
+			Parametro: "tipo_documento",
+			Mensaje:   fmt.Sprintf("el tipo de documento %s no es válido", tipo),
+		}
+	}
+
+	return tipo, services.NormalizarDocumento(tipo, documento), nil
 }
 
 func ObtenerPersonaPorDocumento(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	documento := params["documento"]
+	tipo, documento, err := leerIdentidad(r)
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
 
-	persona, err := services.BuscarPersonaPorDocumento(documento)
+	persona, err := services.BuscarPersonaPorDocumento(r.Context(), tipo, documento)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		escribirError(w, r, err, "Error al buscar la persona")
 		return
 	}
 
-	json.NewEncoder(w).Encode(persona)
+	if noModificado(w, r, etagDePersona(persona), persona.ActualizadoEn) {
+		return
+	}
+	escribirJSON(w, http.StatusOK, persona)
 }
 
 func ActualizarPersona(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	documento := params["documento"]
+	tipo, documento, err := leerIdentidad(r)
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+
+	version, err := leerIfMatch(r)
+	if err != nil {
+		escribirError(w, r, err, "Precondición inválida")
+		return
+	}
 
 	var persona models.Persona
-	err := json.NewDecoder(r.Body).Decode(&persona)
+	err = json.NewDecoder(r.Body).Decode(&persona)
 	if err != nil {
-		http.Error(w, "El formato del cuerpo es inválido", http.StatusBadRequest)
+		escribirProblema(w, r, http.StatusBadRequest, "El formato del cuerpo es inválido", nil)
 		return
 	}
 
-	err = services.ModificarPersona(documento, persona)
+	actualizada, err := services.ModificarPersona(r.Context(), tipo, documento, version, persona)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		escribirError(w, r, err, "Error al actualizar la persona")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"mensaje": "Persona actualizada exitosamente"})
+	escribirPersona(w, http.StatusOK, actualizada)
+}
+
+// tiposParche son los formatos de cuerpo que acepta PATCH. application/json se
+// admite por compatibilidad con clientes que no envían el tipo específico
+var tiposParche = []string{"application/merge-patch+json", "application/json"}
+
+// ParchearPersona modifica parcialmente una persona con un JSON Merge Patch (RFC 7396)
+func ParchearPersona(w http.ResponseWriter, r *http.Request) {
+	tipo, documento, err := leerIdentidad(r)
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+
+	version, err := leerIfMatch(r)
+	if err != nil {
+		escribirError(w, r, err, "Precondición inválida")
+		return
+	}
+
+	tipoContenido, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !slices.Contains(tiposParche, tipoContenido) {
+		w.Header().Set("Accept-Patch", strings.Join(tiposParche, ", "))
+		escribirProblema(w, r, http.StatusUnsupportedMediaType, "El cuerpo debe ser un JSON Merge Patch (application/merge-patch+json)", nil)
+		return
+	}
+
+	var parche map[string]any
+	err = json.NewDecoder(r.Body).Decode(&parche)
+	if err != nil || parche == nil {
+		escribirProblema(w, r, http.StatusBadRequest, "El formato del cuerpo es inválido", nil)
+		return
+	}
+
+	actualizada, err := services.ParchearPersona(r.Context(), tipo, documento, version, parche)
+	if err != nil {
+		escribirError(w, r, err, "Error al actualizar la persona")
+		return
+	}
+
+	escribirPersona(w, http.StatusOK, actualizada)
 }
 
 func EliminarPersona(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	documento := params["documento"]
+	tipo, documento, err := leerIdentidad(r)
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+
+	version, err := leerIfMatch(r)
+	if err != nil {
+		escribirError(w, r, err, "Precondición inválida")
+		return
+	}
+
+	err = services.BorrarPersona(r.Context(), tipo, documento, version)
+	if err != nil {
+		escribirError(w, r, err, "Error al eliminar la persona")
+		return
+	}
+
+	escribirJSON(w, http.StatusOK, map[string]string{"mensaje": "Persona eliminada exitosamente"})
+}
+
+// RestaurarPersona deshace la eliminación de una persona. If-Match es opcional:
+// sin él se restaura la persona sin importar su versión
+func RestaurarPersona(w http.ResponseWriter, r *http.Request) {
+	tipo, documento, err := leerIdentidad(r)
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
+
+	version := services.VersionCualquiera
+	if r.Header.Get("If-Match") != "" {
+		if version, err = leerIfMatch(r); err != nil {
+			escribirError(w, r, err, "Precondición inválida")
+			return
+		}
+	}
+
+	err = services.RestaurarPersona(r.Context(), tipo, documento, version)
+	if err != nil {
+		escribirError(w, r, err, "Error al restaurar la persona")
+		return
+	}
+
+	escribirJSON(w, http.StatusOK, map[string]string{"mensaje": "Persona restaurada exitosamente"})
+}
+
+// PurgarPersona borra definitivamente una persona eliminada. Es una operación de
+// administración: el gateway solo debe exponer las rutas /admin a los administradores
+func PurgarPersona(w http.ResponseWriter, r *http.Request) {
+	tipo, documento, err := leerIdentidad(r)
+	if err != nil {
+		escribirError(w, r, err, "Parámetros inválidos")
+		return
+	}
 
-	err := services.BorrarPersona(documento)
+	err = services.PurgarPersona(r.Context(), tipo, documento)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		escribirError(w, r, err, "Error al purgar la persona")
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{"mensaje": "Persona eliminada exitosamente"})
+	escribirJSON(w, http.StatusOK, map[string]string{"mensaje": "Persona purgada definitivamente"})
 }
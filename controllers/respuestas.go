@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+)
+
+// escribirJSON envía una respuesta exitosa en formato JSON con el código indicado
+func escribirJSON(w http.ResponseWriter, estado int, cuerpo any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(estado)
+	json.NewEncoder(w).Encode(cuerpo)
+}
+
+// rutaPersonas es la ruta del recurso de personas con la que se arma la cabecera Location
+var rutaPersonas = "/api/v1/personas"
+
+// SetRutaPersonas define la ruta del recurso de personas
+func SetRutaPersonas(ruta string) {
+	rutaPersonas = ruta
+}
+
+// ubicacionPersona es la URL de una persona dentro del recurso. El tipo de documento
+// solo se agrega cuando no es el que se asume por defecto
+func ubicacionPersona(tipo models.TipoDocumento, documento string) string {
+	ubicacion := rutaPersonas + "/" + url.PathEscape(documento)
+	if tipo != models.TipoDocumentoPorDefecto {
+		ubicacion += "?tipo_documento=" + url.QueryEscape(string(tipo))
+	}
+	return ubicacion
+}
+
+// escribirPersona responde con la persona guardada, su ubicación en Location y su
+// versión en la ETag, para que el cliente pueda seguir escribiendo sin releerla
+func escribirPersona(w http.ResponseWriter, estado int, p models.Persona) {
+	w.Header().Set("Location", ubicacionPersona(p.TipoDocumento, p.Documento))
+	w.Header().Set("ETag", etagDePersona(p))
+	escribirJSON(w, estado, p)
+}
+
+// sinPlazos quita a la petición los tiempos máximos de lectura y escritura del
+// servidor. Solo se usa al transferir archivos completos y en las operaciones masivas,
+// cuya duración depende de la cantidad de personas; la petición se sigue cancelando si
+// el cliente se desconecta
+func sinPlazos(w http.ResponseWriter) {
+	controlador := http.NewResponseController(w)
+	controlador.SetReadDeadline(time.Time{})
+	controlador.SetWriteDeadline(time.Time{})
+}
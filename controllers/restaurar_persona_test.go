@@ -0,0 +1,97 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRestaurarPersonaController_Success(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaEliminada", mock.Anything, models.CC, "123").Return(models.Persona{ID: idPersona, Documento: "123", Version: 2, EliminadoEn: instante}, nil)
+	mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123", int64(2), mock.Anything).Return(nil)
+
+	req := httptest.NewRequest("POST", "/personas/123/restaurar", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.RestaurarPersona(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Persona restaurada exitosamente")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestaurarPersonaController_VersionDesactualizada(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaEliminada", mock.Anything, models.CC, "123").Return(models.Persona{ID: idPersona, Documento: "123", Version: 2, EliminadoEn: instante}, nil)
+
+	req := httptest.NewRequest("POST", "/personas/123/restaurar", nil)
+	req.Header.Set("If-Match", etag(idPersona, 1))
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.RestaurarPersona(rr, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+}
+
+func TestRestaurarPersonaController_NoEliminada(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("ObtenerPersonaEliminada", mock.Anything, models.CC, "123").Return(models.Persona{}, mongo.ErrNoDocuments)
+
+	req := httptest.NewRequest("POST", "/personas/123/restaurar", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.RestaurarPersona(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPurgarPersonaController_Success(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("PurgarPersona", mock.Anything, models.TI, "1020304050").Return(nil)
+
+	req := httptest.NewRequest("DELETE", "/admin/personas/1020304050?tipo_documento=TI", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "1020304050"})
+	rr := httptest.NewRecorder()
+
+	controllers.PurgarPersona(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Persona purgada definitivamente")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgarPersonaController_NoEliminada(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("PurgarPersona", mock.Anything, models.CC, "123").Return(mongo.ErrNoDocuments)
+
+	req := httptest.NewRequest("DELETE", "/admin/personas/123", nil)
+	req = mux.SetURLVars(req, map[string]string{"documento": "123"})
+	rr := httptest.NewRecorder()
+
+	controllers.PurgarPersona(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/config"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+)
+
+// Verificacion comprueba que una dependencia esté disponible
+type Verificacion func(ctx context.Context) error
+
+// TimeoutVerificacion es el tiempo máximo de cada verificación de /readyz. Debe ser
+// menor que el timeout de la sonda del orquestador
+const TimeoutVerificacion = 2 * time.Second
+
+// dependencias son las verificaciones que deben pasar para recibir tráfico
+var dependencias = map[string]Verificacion{"mongo": config.PingMongo}
+
+// SetDependencias reemplaza las verificaciones de /readyz (ideal para pruebas)
+func SetDependencias(d map[string]Verificacion) {
+	dependencias = d
+}
+
+// compilacion devuelve los datos del binario inyectados al enlazar
+func compilacion() models.Compilacion {
+	return models.Compilacion{Version: config.Version, Commit: config.Commit, Fecha: config.FechaCompilacion}
+}
+
+// Vivo responde 200 mientras el proceso pueda atender peticiones. No revisa las
+// dependencias: una base de datos caída no se arregla reiniciando el servicio
+func Vivo(w http.ResponseWriter, r *http.Request) {
+	escribirJSON(w, http.StatusOK, models.Salud{Estado: models.EstadoOK, Compilacion: compilacion()})
+}
+
+// Listo comprueba cada dependencia con un tiempo máximo corto y responde 503 si
+// alguna falla, para que el balanceador deje de enviarle tráfico
+func Listo(w http.ResponseWriter, r *http.Request) {
+	salud := models.Salud{
+		Estado:       models.EstadoListo,
+		Dependencias: make(map[string]models.EstadoDependencia, len(dependencias)),
+		Compilacion:  compilacion(),
+	}
+
+	for nombre, verificar := range dependencias {
+		estado := comprobar(r.Context(), verificar)
+		if estado.Estado != models.EstadoOK {
+			salud.Estado = models.EstadoNoListo
+		}
+		salud.Dependencias[nombre] = estado
+	}
+
+	codigo := http.StatusOK
+	if salud.Estado != models.EstadoListo {
+		codigo = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	escribirJSON(w, codigo, salud)
+}
+
+// comprobar ejecuta una verificación y mide cuánto tardó
+func comprobar(ctx context.Context, verificar Verificacion) models.EstadoDependencia {
+	ctx, cancel := context.WithTimeout(ctx, TimeoutVerificacion)
+	defer cancel()
+
+	inicio := time.Now()
+	err := verificar(ctx)
+	estado := models.EstadoDependencia{Estado: models.EstadoOK, DuracionMs: time.Since(inicio).Milliseconds()}
+	if err != nil {
+		estado.Estado = models.EstadoError
+		estado.Error = err.Error()
+	}
+	return estado
+}
@@ -0,0 +1,86 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/config"
+	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func conDependencias(t *testing.T, dependencias map[string]controllers.Verificacion) {
+	controllers.SetDependencias(dependencias)
+	t.Cleanup(func() {
+		controllers.SetDependencias(map[string]controllers.Verificacion{"mongo": config.PingMongo})
+	})
+}
+
+func TestVivo(t *testing.T) {
+	// Vivo no consulta las dependencias, aunque fallen
+	conDependencias(t, map[string]controllers.Verificacion{
+		"mongo": func(context.Context) error { return errors.New("servidor no disponible") },
+	})
+
+	rr := httptest.NewRecorder()
+	controllers.Vivo(rr, httptest.NewRequest("GET", "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var salud models.Salud
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &salud))
+	assert.Equal(t, models.EstadoOK, salud.Estado)
+	assert.Equal(t, config.Version, salud.Compilacion.Version)
+}
+
+func TestListo(t *testing.T) {
+	t.Run("Debe responder 200 si todas las dependencias responden", func(t *testing.T) {
+		conDependencias(t, map[string]controllers.Verificacion{
+			"mongo": func(context.Context) error { return nil },
+		})
+
+		rr := httptest.NewRecorder()
+		controllers.Listo(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var salud models.Salud
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &salud))
+		assert.Equal(t, models.EstadoListo, salud.Estado)
+		assert.Equal(t, models.EstadoOK, salud.Dependencias["mongo"].Estado)
+	})
+
+	t.Run("Debe responder 503 y detallar la dependencia que falla", func(t *testing.T) {
+		conDependencias(t, map[string]controllers.Verificacion{
+			"mongo": func(ctx context.Context) error {
+				// La verificación recibe un contexto con tiempo máximo
+				if _, ok := ctx.Deadline(); !ok {
+					return errors.New("sin tiempo máximo")
+				}
+				return errors.New("servidor no disponible")
+			},
+			"cache": func(context.Context) error { return nil },
+		})
+
+		rr := httptest.NewRecorder()
+		controllers.Listo(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		var salud models.Salud
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &salud))
+		assert.Equal(t, models.EstadoNoListo, salud.Estado)
+		assert.Equal(t, "servidor no disponible", salud.Dependencias["mongo"].Error)
+		assert.Equal(t, models.EstadoOK, salud.Dependencias["cache"].Estado)
+	})
+
+	t.Run("Sin conexión a MongoDB no está listo", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		controllers.Listo(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Contains(t, rr.Body.String(), "no hay conexión con MongoDB")
+	})
+}
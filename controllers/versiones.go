@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// etagDePersona arma la ETag fuerte de una persona con su _id y su versión. La
+// versión sola se repetiría si la persona se purga y su documento se vuelve a
+// registrar, porque la nueva empieza otra vez en la versión inicial
+func etagDePersona(p models.Persona) string {
+	return `"` + p.ID.Hex() + "-" + strconv.FormatInt(p.Version, 10) + `"`
+}
+
+// leerIfMatch obtiene de la cabecera If-Match la versión que el cliente espera
+// modificar. "*" acepta cualquier versión; una ETag débil o que no sea nuestra
+// nunca coincide, por lo que se responde como precondición fallida
+func leerIfMatch(r *http.Request) (services.VersionEsperada, error) {
+	valor := strings.TrimSpace(r.Header.Get("If-Match"))
+	if valor == "" {
+		return services.VersionEsperada{}, services.ErrPreconditionRequired
+	}
+	if valor == "*" {
+		return services.VersionCualquiera, nil
+	}
+
+	sinComillas, ok := strings.CutPrefix(valor, `"`)
+	if !ok {
+		return services.VersionEsperada{}, services.ErrPreconditionFailed
+	}
+	sinComillas, ok = strings.CutSuffix(sinComillas, `"`)
+	if !ok {
+		return services.VersionEsperada{}, services.ErrPreconditionFailed
+	}
+
+	hex, numero, ok := strings.Cut(sinComillas, "-")
+	if !ok {
+		return services.VersionEsperada{}, services.ErrPreconditionFailed
+	}
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return services.VersionEsperada{}, services.ErrPreconditionFailed
+	}
+	version, err := strconv.ParseInt(numero, 10, 64)
+	if err != nil || version <= 0 {
+		return services.VersionEsperada{}, services.ErrPreconditionFailed
+	}
+	return services.VersionEsperada{ID: id, Numero: version}, nil
+}
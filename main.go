@@ -1,19 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/danysoftdev/microservicio-go-mongodb/config"
-	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
 	"github.com/danysoftdev/microservicio-go-mongodb/repositories"
+	"github.com/danysoftdev/microservicio-go-mongodb/routes"
 	"github.com/danysoftdev/microservicio-go-mongodb/services"
-
-	"github.com/gorilla/mux"
 )
 
 func main() {
+	log.Printf("📦 Versión %s (commit %s, compilado %s)", config.Version, config.Commit, config.FechaCompilacion)
+
 	// Conectamos a MongoDB
 	err := config.ConectarMongo()
 	if err != nil {
@@ -27,22 +32,82 @@ func main() {
 	// 3. Inyectar la colección de MongoDB
 	repositories.SetCollection(config.Collection)
 
-	// Creamos el enrutador
-	router := mux.NewRouter()
+	// Historial de cambios de las personas
+	repositories.SetColeccionHistorial(config.ColeccionHistorial)
+	services.SetHistorialRepository(repositories.RealHistorialRepository{})
+
+	// Reglas de validación configurables
+	services.SetEdadMaxima(config.EnteroDeEntorno("EDAD_MAXIMA", services.EdadMaximaPorDefecto))
+
+	// Tiempos máximos de las operaciones contra MongoDB
+	repositories.SetTimeouts(
+		config.DuracionDeEntorno("MONGO_TIMEOUT_OPERACION", repositories.TimeoutOperacionPorDefecto),
+		config.DuracionDeEntorno("MONGO_TIMEOUT_LISTADO", repositories.TimeoutListadoPorDefecto),
+	)
+
+	// Tiempo que se conservan las personas eliminadas antes de purgarlas
+	repositories.SetRetencionEliminados(config.DuracionDeEntorno("RETENCION_ELIMINADOS", repositories.RetencionEliminadosPorDefecto))
+
+	// 4. Migrar los registros antiguos y asegurar los índices de la colección
+	ctx := context.Background()
+	if err := repositories.MigrarPersonas(ctx); err != nil {
+		log.Fatal("❌ Error migrando personas:", err)
+	}
+	if err := repositories.MigrarTelefonos(ctx, services.NormalizarTelefono); err != nil {
+		log.Fatal("❌ Error migrando teléfonos:", err)
+	}
+	if err := repositories.CrearIndices(ctx); err != nil {
+		log.Fatal("❌ Error creando índices en MongoDB:", err)
+	}
+	if err := repositories.CrearIndicesHistorial(ctx); err != nil {
+		log.Fatal("❌ Error creando índices del historial:", err)
+	}
+
+	// Creamos el enrutador con las rutas de la API
+	router := routes.NuevoRouter()
 
-	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintln(w, "Hello World")
-	})
+	// Servidor HTTP con tiempos máximos para no quedar a merced de clientes lentos
+	cfg := config.CargarServidor()
+	servidor := &http.Server{
+		Addr:              cfg.Direccion,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.TimeoutCabeceras,
+		ReadTimeout:       cfg.TimeoutLectura,
+		WriteTimeout:      cfg.TimeoutEscritura,
+		IdleTimeout:       cfg.TimeoutInactividad,
+		MaxHeaderBytes:    cfg.MaximoCabeceras,
+	}
 
-	// Rutas de la API
-	router.HandleFunc("/crear-personas", controllers.CrearPersona).Methods("POST")
-	router.HandleFunc("/listar-personas", controllers.ObtenerPersonas).Methods("GET")
-	router.HandleFunc("/buscar-personas/{documento}", controllers.ObtenerPersonaPorDocumento).Methods("GET")
-	router.HandleFunc("/actualizar-personas/{documento}", controllers.ActualizarPersona).Methods("PUT")
-	router.HandleFunc("/eliminar-personas/{documento}", controllers.EliminarPersona).Methods("DELETE")
+	errores := make(chan error, 1)
+	go func() {
+		fmt.Printf("🚀 Servidor escuchando en %s\n", cfg.Direccion)
+		errores <- servidor.ListenAndServe()
+	}()
 
-	// Puerto de escucha
-	puerto := ":8080"
-	fmt.Printf("🚀 Servidor escuchando en http://localhost%s\n", puerto)
-	log.Fatal(http.ListenAndServe(puerto, router))
+	// Al recibir SIGINT o SIGTERM se dejan de aceptar conexiones y se esperan las
+	// peticiones en curso; solo después se cierra la conexión con MongoDB
+	senales, detener := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer detener()
+
+	select {
+	case err := <-errores:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("❌ Error en el servidor HTTP:", err)
+		}
+	case <-senales.Done():
+		detener()
+		log.Printf("🛑 Apagando el servidor, se esperan las peticiones en curso hasta %s", cfg.TiempoApagado)
+	}
+
+	ctxApagado, cancelar := context.WithTimeout(context.Background(), cfg.TiempoApagado)
+	defer cancelar()
+	if err := servidor.Shutdown(ctxApagado); err != nil {
+		log.Println("⚠️ No terminaron todas las peticiones a tiempo:", err)
+		servidor.Close()
+	}
+
+	if err := config.CerrarMongo(); err != nil {
+		log.Println("⚠️ Error cerrando la conexión con MongoDB:", err)
+	}
+	log.Println("👋 Servidor detenido")
 }
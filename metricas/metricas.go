@@ -0,0 +1,103 @@
+package metricas
+
+import (
+	"net/http"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registro reúne las métricas que expone /metrics. Se usa un registro propio en lugar
+// del global para no publicar las métricas que registren las dependencias
+var Registro = prometheus.NewRegistry()
+
+var (
+	// PeticionesHTTP y DuracionHTTP se etiquetan con la plantilla de la ruta y no con
+	// la ruta recibida, para que cada documento no cree una serie nueva
+	PeticionesHTTP = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_peticiones_total",
+		Help: "Peticiones HTTP atendidas, por plantilla de ruta, método y código de estado.",
+	}, []string{"ruta", "metodo", "estado"})
+
+	DuracionHTTP = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_peticion_duracion_segundos",
+		Help:    "Tiempo de atención de las peticiones HTTP, por plantilla de ruta, método y código de estado.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"ruta", "metodo", "estado"})
+
+	ComandosMongo = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_comandos_total",
+		Help: "Comandos enviados a MongoDB, por comando y resultado (ok o error).",
+	}, []string{"comando", "resultado"})
+
+	DuracionMongo = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongo_comando_duracion_segundos",
+		Help:    "Tiempo de respuesta de MongoDB, por comando.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"comando"})
+
+	ConexionesAbiertas = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mongo_conexiones_abiertas",
+		Help: "Conexiones abiertas en el pool de MongoDB.",
+	})
+
+	ConexionesEnUso = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mongo_conexiones_en_uso",
+		Help: "Conexiones del pool de MongoDB prestadas a una operación.",
+	})
+
+	EsperaConexion = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mongo_espera_conexion_segundos",
+		Help:    "Tiempo que esperan las operaciones por una conexión del pool de MongoDB.",
+		Buckets: []float64{.0005, .001, .005, .01, .05, .1, .5, 1, 5},
+	})
+
+	ConexionesFallidas = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_conexiones_fallidas_total",
+		Help: "Operaciones que no obtuvieron una conexión del pool de MongoDB, por motivo.",
+	}, []string{"motivo"})
+
+	PoolVaciado = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mongo_pool_vaciados_total",
+		Help: "Veces que el driver descartó las conexiones del pool por un error del servidor.",
+	})
+
+	OperacionesPersonas = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "personas_operaciones_total",
+		Help: "Personas escritas con éxito, por operación (crear, actualizar, eliminar, restaurar o purgar).",
+	}, []string{"operacion"})
+)
+
+func init() {
+	Registro.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		PeticionesHTTP, DuracionHTTP,
+		ComandosMongo, DuracionMongo,
+		ConexionesAbiertas, ConexionesEnUso, EsperaConexion, ConexionesFallidas, PoolVaciado,
+		OperacionesPersonas,
+	)
+
+	// Las operaciones se inicializan en cero para que las consultas de tasas funcionen
+	// antes de la primera escritura
+	for _, op := range []models.Operacion{
+		models.OperacionCrear, models.OperacionActualizar, models.OperacionEliminar,
+		models.OperacionRestaurar, models.OperacionPurgar,
+	} {
+		OperacionesPersonas.WithLabelValues(string(op))
+	}
+}
+
+// ContarPersonas suma n personas a las escritas con la operación
+func ContarPersonas(operacion models.Operacion, n int64) {
+	if n > 0 {
+		OperacionesPersonas.WithLabelValues(string(operacion)).Add(float64(n))
+	}
+}
+
+// Handler expone el registro en el formato de texto de Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registro, promhttp.HandlerOpts{Registry: Registro})
+}
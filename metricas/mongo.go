@@ -0,0 +1,47 @@
+package metricas
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// MonitorComandos registra cuántos comandos se envían a MongoDB, cuánto tardan y
+// cuántos fallan. Se pasa al cliente con options.Client().SetMonitor
+func MonitorComandos() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			ComandosMongo.WithLabelValues(e.CommandName, "ok").Inc()
+			DuracionMongo.WithLabelValues(e.CommandName).Observe(e.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			ComandosMongo.WithLabelValues(e.CommandName, "error").Inc()
+			DuracionMongo.WithLabelValues(e.CommandName).Observe(e.Duration.Seconds())
+		},
+	}
+}
+
+// MonitorPool sigue el estado del pool de conexiones de MongoDB. Se pasa al cliente
+// con options.Client().SetPoolMonitor
+func MonitorPool() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				ConexionesAbiertas.Inc()
+			case event.ConnectionClosed:
+				ConexionesAbiertas.Dec()
+			case event.GetSucceeded:
+				ConexionesEnUso.Inc()
+				EsperaConexion.Observe(e.Duration.Seconds())
+			case event.ConnectionReturned:
+				ConexionesEnUso.Dec()
+			case event.GetFailed:
+				ConexionesFallidas.WithLabelValues(e.Reason).Inc()
+				EsperaConexion.Observe(e.Duration.Seconds())
+			case event.PoolCleared:
+				PoolVaciado.Inc()
+			}
+		},
+	}
+}
@@ -0,0 +1,44 @@
+package metricas_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/metricas"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestMonitorComandos(t *testing.T) {
+	monitor := metricas.MonitorComandos()
+	exitosos := metricas.ComandosMongo.WithLabelValues("find", "ok")
+	fallidos := metricas.ComandosMongo.WithLabelValues("find", "error")
+	antesOK, antesError := testutil.ToFloat64(exitosos), testutil.ToFloat64(fallidos)
+
+	terminado := event.CommandFinishedEvent{CommandName: "find", Duration: 3 * time.Millisecond}
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: terminado})
+	monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: terminado})
+	monitor.Failed(context.Background(), &event.CommandFailedEvent{CommandFinishedEvent: terminado, Failure: "timeout"})
+
+	assert.Equal(t, antesOK+2, testutil.ToFloat64(exitosos))
+	assert.Equal(t, antesError+1, testutil.ToFloat64(fallidos))
+}
+
+func TestMonitorPool(t *testing.T) {
+	monitor := metricas.MonitorPool()
+	abiertas := testutil.ToFloat64(metricas.ConexionesAbiertas)
+	enUso := testutil.ToFloat64(metricas.ConexionesEnUso)
+	porTimeout := metricas.ConexionesFallidas.WithLabelValues(event.ReasonTimedOut)
+	antesTimeout := testutil.ToFloat64(porTimeout)
+
+	for _, tipo := range []string{event.ConnectionCreated, event.ConnectionCreated, event.GetSucceeded, event.GetSucceeded, event.ConnectionReturned, event.ConnectionClosed} {
+		monitor.Event(&event.PoolEvent{Type: tipo})
+	}
+	monitor.Event(&event.PoolEvent{Type: event.GetFailed, Reason: event.ReasonTimedOut, Duration: time.Second})
+
+	assert.Equal(t, abiertas+1, testutil.ToFloat64(metricas.ConexionesAbiertas))
+	assert.Equal(t, enUso+1, testutil.ToFloat64(metricas.ConexionesEnUso))
+	assert.Equal(t, antesTimeout+1, testutil.ToFloat64(porTimeout))
+}
@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/metricas"
+	"github.com/gorilla/mux"
+)
+
+// Metricas cuenta cada petición y mide su duración, etiquetadas con la plantilla de
+// la ruta, el método y el código de estado. Solo se aplica a las peticiones que
+// coinciden con una ruta del router
+func Metricas(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ruta := "desconocida"
+		if actual := mux.CurrentRoute(r); actual != nil {
+			if plantilla, err := actual.GetPathTemplate(); err == nil {
+				ruta = plantilla
+			}
+		}
+
+		respuesta := &respuestaMedida{ResponseWriter: w, estado: http.StatusOK}
+		inicio := time.Now()
+		// Se registra en un defer para contar también las respuestas abortadas con panic
+		defer func() {
+			estado := strconv.Itoa(respuesta.estado)
+			metricas.PeticionesHTTP.WithLabelValues(ruta, r.Method, estado).Inc()
+			metricas.DuracionHTTP.WithLabelValues(ruta, r.Method, estado).Observe(time.Since(inicio).Seconds())
+		}()
+
+		next.ServeHTTP(respuesta, r)
+	})
+}
+
+// respuestaMedida guarda el código de estado que escribe el controlador
+type respuestaMedida struct {
+	http.ResponseWriter
+	estado  int
+	escrito bool
+}
+
+func (r *respuestaMedida) WriteHeader(estado int) {
+	if !r.escrito {
+		r.estado = estado
+		r.escrito = true
+	}
+	r.ResponseWriter.WriteHeader(estado)
+}
+
+func (r *respuestaMedida) Write(b []byte) (int, error) {
+	r.escrito = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap permite a http.NewResponseController llegar a la respuesta original
+func (r *respuestaMedida) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
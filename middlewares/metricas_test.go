@@ -0,0 +1,59 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/metricas"
+	"github.com/danysoftdev/microservicio-go-mongodb/middlewares"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricas_EtiquetaConLaPlantillaDeLaRuta(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(middlewares.Metricas)
+	router.HandleFunc("/personas/{documento}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+
+	peticiones := metricas.PeticionesHTTP.WithLabelValues("/personas/{documento}", "GET", "404")
+	antes := testutil.ToFloat64(peticiones)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/personas/123", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/personas/456", nil))
+
+	assert.Equal(t, antes+2, testutil.ToFloat64(peticiones))
+}
+
+func TestMetricas_SinWriteHeaderEs200(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(middlewares.Metricas)
+	router.HandleFunc("/hola", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hola"))
+		// Un segundo código no cambia el que ya se envió
+		w.WriteHeader(http.StatusInternalServerError)
+	}).Methods("POST")
+
+	peticiones := metricas.PeticionesHTTP.WithLabelValues("/hola", "POST", "200")
+	antes := testutil.ToFloat64(peticiones)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/hola", nil))
+
+	assert.Equal(t, antes+1, testutil.ToFloat64(peticiones))
+}
+
+func TestMetricas_ConservaLaRespuestaOriginal(t *testing.T) {
+	var errFlush error
+	handler := middlewares.Metricas(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errFlush = http.NewResponseController(w).Flush()
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.NoError(t, errFlush)
+	assert.True(t, rr.Flushed)
+}
@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/contexto"
+)
+
+const CabeceraRequestID = "X-Request-ID"
+
+// RequestID reutiliza el X-Request-ID que envía el gateway o genera uno nuevo,
+// lo guarda en el contexto de la petición y lo devuelve en la respuesta
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(CabeceraRequestID)
+		if id == "" {
+			id = nuevoRequestID()
+		}
+
+		w.Header().Set(CabeceraRequestID, id)
+		next.ServeHTTP(w, r.WithContext(contexto.ConRequestID(r.Context(), id)))
+	})
+}
+
+func nuevoRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
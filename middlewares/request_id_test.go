@@ -0,0 +1,40 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/contexto"
+	"github.com/danysoftdev/microservicio-go-mongodb/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID_ReutilizaCabecera(t *testing.T) {
+	var enContexto string
+	handler := middlewares.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enContexto = contexto.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, "abc-123", enContexto)
+	assert.Equal(t, "abc-123", rr.Header().Get("X-Request-ID"))
+}
+
+func TestRequestID_GeneraUnoNuevo(t *testing.T) {
+	var enContexto string
+	handler := middlewares.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enContexto = contexto.RequestID(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Len(t, enContexto, 32)
+	assert.Equal(t, enContexto, rr.Header().Get("X-Request-ID"))
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/contexto"
+)
+
+// CabeceraUsuario la agrega el gateway con el usuario que autenticó. El servicio
+// no está expuesto directamente, así que confía en ella
+const CabeceraUsuario = "X-Usuario"
+
+// Usuario guarda en el contexto de la petición el usuario autenticado por el gateway
+func Usuario(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usuario := strings.TrimSpace(r.Header.Get(CabeceraUsuario))
+		if usuario != "" {
+			r = r.WithContext(contexto.ConUsuario(r.Context(), usuario))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
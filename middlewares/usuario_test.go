@@ -0,0 +1,35 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/contexto"
+	"github.com/danysoftdev/microservicio-go-mongodb/middlewares"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsuario_TomaLaCabecera(t *testing.T) {
+	var enContexto string
+	handler := middlewares.Usuario(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enContexto = contexto.Usuario(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Usuario", " operador@empresa.co ")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "operador@empresa.co", enContexto)
+}
+
+func TestUsuario_SinCabecera(t *testing.T) {
+	enContexto := "sin ejecutar"
+	handler := middlewares.Usuario(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enContexto = contexto.Usuario(r.Context())
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Empty(t, enContexto)
+}
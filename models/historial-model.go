@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Operacion es el tipo de escritura que quedó registrada en el historial
+type Operacion string
+
+const (
+	OperacionCrear      Operacion = "crear"
+	OperacionActualizar Operacion = "actualizar"
+	OperacionEliminar   Operacion = "eliminar"
+	OperacionRestaurar  Operacion = "restaurar"
+	OperacionPurgar     Operacion = "purgar"
+)
+
+// CambioCampo guarda el valor de un campo antes y después de la operación. Un
+// valor nulo indica que el campo no existía
+type CambioCampo struct {
+	Antes   any `bson:"antes" json:"antes"`
+	Despues any `bson:"despues" json:"despues"`
+}
+
+// CambioPersona es una entrada del historial de una persona: qué campos cambió
+// cada operación, quién la hizo, cuándo y en qué petición
+type CambioPersona struct {
+	ID            primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	TipoDocumento TipoDocumento          `bson:"tipo_documento" json:"tipo_documento"`
+	Documento     string                 `bson:"documento" json:"documento"`
+	Operacion     Operacion              `bson:"operacion" json:"operacion"`
+	Version       int64                  `bson:"version,omitempty" json:"version,omitempty"`
+	Cambios       map[string]CambioCampo `bson:"cambios" json:"cambios"`
+	Actor         string                 `bson:"actor" json:"actor"`
+	Fecha         time.Time              `bson:"fecha" json:"fecha"`
+	RequestID     string                 `bson:"request_id,omitempty" json:"request_id,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// ErrorImportacion explica por qué una fila del archivo no se creó ni se actualizó
+type ErrorImportacion struct {
+	Linea     int    `json:"linea"`
+	Documento string `json:"documento,omitempty"`
+	Estado    string `json:"estado"`
+	Motivo    string `json:"motivo"`
+}
+
+// ReporteImportacion resume una importación con la cantidad de filas por resultado
+// y el detalle de las que se omitieron o fallaron
+type ReporteImportacion struct {
+	Creadas      int                `json:"creadas"`
+	Actualizadas int                `json:"actualizadas"`
+	Omitidas     int                `json:"omitidas"`
+	Fallidas     int                `json:"fallidas"`
+	Errores      []ErrorImportacion `json:"errores"`
+}
@@ -0,0 +1,43 @@
+package models
+
+// CamposOrdenables son los campos por los que se puede ordenar el listado de personas
+var CamposOrdenables = []string{"apellido", "nombre", "edad", "documento"}
+
+// FiltroPersonas reúne los criterios por campo del listado; los vacíos no filtran
+type FiltroPersonas struct {
+	Apellido        string
+	DominioCorreo   string
+	EdadMin         *int
+	EdadMax         *int
+	PrefijoTelefono string
+	Direccion       string
+
+	// IncluirEliminados agrega al resultado las personas eliminadas que no se han purgado
+	IncluirEliminados bool
+}
+
+// Vacio indica si el filtro no restringe ningún campo
+func (f FiltroPersonas) Vacio() bool {
+	return f.Apellido == "" && f.DominioCorreo == "" && f.EdadMin == nil && f.EdadMax == nil &&
+		f.PrefijoTelefono == "" && f.Direccion == ""
+}
+
+// ConsultaPersonas agrupa las opciones de paginación, orden y filtrado del listado
+type ConsultaPersonas struct {
+	Pagina      int
+	Limite      int
+	Despues     string
+	Orden       string
+	Descendente bool
+	Filtro      FiltroPersonas
+}
+
+// PaginaPersonas es el sobre con el que se responde el listado paginado
+type PaginaPersonas struct {
+	Datos           []Persona         `json:"datos"`
+	Total           int64             `json:"total"`
+	Pagina          int               `json:"pagina,omitempty"`
+	Limite          int               `json:"limite"`
+	SiguienteCursor string            `json:"next_cursor,omitempty"`
+	Enlaces         map[string]string `json:"links,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// ResultadoLote es el resultado de una persona dentro de una operación masiva. Estado
+// es el código HTTP que habría tenido la operación sobre esa persona por separado
+type ResultadoLote struct {
+	Indice  int          `json:"indice"`
+	Estado  int          `json:"estado"`
+	ID      string       `json:"id,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Errores []ErrorCampo `json:"errores,omitempty"`
+}
+
+// RespuestaLote resume una creación masiva con el resultado de cada persona en el orden recibido
+type RespuestaLote struct {
+	Creadas    int             `json:"creadas"`
+	Fallidas   int             `json:"fallidas"`
+	Resultados []ResultadoLote `json:"resultados"`
+}
@@ -0,0 +1,23 @@
+package models
+
+// Reemplazo cambia un texto por otro dentro de un campo, conservando el resto del valor
+type Reemplazo struct {
+	Buscar    string `json:"buscar"`
+	Reemplazo string `json:"reemplazo"`
+}
+
+// ActualizacionMasiva describe los cambios que se aplican a todas las personas de un
+// filtro: valores que se asignan tal cual y reemplazos de texto dentro de un campo
+type ActualizacionMasiva struct {
+	Cambios    map[string]any       `json:"cambios"`
+	Reemplazos map[string]Reemplazo `json:"reemplazos"`
+}
+
+// ResultadoMasivo resume una operación masiva. En una simulación no se escribe nada:
+// Modificadas cuenta las personas que cambiarían y Muestra trae algunas de ellas
+type ResultadoMasivo struct {
+	Simulacion   bool      `json:"simulacion"`
+	Coincidentes int64     `json:"coincidentes"`
+	Modificadas  int64     `json:"modificadas"`
+	Muestra      []Persona `json:"muestra,omitempty"`
+}
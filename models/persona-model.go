@@ -1,14 +1,62 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TipoDocumento identifica la clase de documento de identidad; junto con el número
+// forma la identidad de la persona (CC 123 y TI 123 son personas distintas)
+type TipoDocumento string
+
+const (
+	CC  TipoDocumento = "CC"  // Cédula de ciudadanía
+	TI  TipoDocumento = "TI"  // Tarjeta de identidad
+	CE  TipoDocumento = "CE"  // Cédula de extranjería
+	NIT TipoDocumento = "NIT" // Número de identificación tributaria
+	PA  TipoDocumento = "PA"  // Pasaporte
+)
+
+// TipoDocumentoPorDefecto se asume cuando no se indica el tipo y se asigna a los
+// registros creados antes de que existiera el campo
+const TipoDocumentoPorDefecto = CC
+
+// VersionInicial es la versión con la que se crea una persona. Cada escritura la
+// incrementa en uno y los registros anteriores al campo se migran a este valor
+const VersionInicial int64 = 1
+
+// TiposDocumento son todos los tipos de documento aceptados
+var TiposDocumento = []TipoDocumento{CC, TI, CE, NIT, PA}
+
+// Valido indica si el tipo de documento es uno de los aceptados
+func (t TipoDocumento) Valido() bool {
+	for _, tipo := range TiposDocumento {
+		if t == tipo {
+			return true
+		}
+	}
+	return false
+}
 
 type Persona struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Documento string             `bson:"documento" json:"documento"`
-	Nombre    string             `bson:"nombre" json:"nombre"`
-	Apellido  string             `bson:"apellido" json:"apellido"`
-	Edad      int                `bson:"edad" json:"edad"`
-	Correo    string             `bson:"correo" json:"correo"`
-	Telefono  string             `bson:"telefono" json:"telefono"`
-	Direccion string             `bson:"direccion" json:"direccion"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TipoDocumento TipoDocumento      `bson:"tipo_documento" json:"tipo_documento"`
+	Documento     string             `bson:"documento" json:"documento"`
+	Nombre        string             `bson:"nombre" json:"nombre"`
+	Apellido      string             `bson:"apellido" json:"apellido"`
+	Edad          int                `bson:"edad" json:"edad"`
+	Correo        string             `bson:"correo" json:"correo"`
+	Telefono      string             `bson:"telefono" json:"telefono"`
+	Direccion     string             `bson:"direccion" json:"direccion"`
+	Version       int64              `bson:"version" json:"version"`
+
+	// Datos de auditoría: los asigna el servicio y se ignoran si los envía el cliente
+	CreadoEn       time.Time `bson:"creado_en,omitempty" json:"creado_en,omitzero"`
+	CreadoPor      string    `bson:"creado_por,omitempty" json:"creado_por,omitempty"`
+	ActualizadoEn  time.Time `bson:"actualizado_en,omitempty" json:"actualizado_en,omitzero"`
+	ActualizadoPor string    `bson:"actualizado_por,omitempty" json:"actualizado_por,omitempty"`
+
+	// EliminadoEn marca a la persona como eliminada. Mientras no se purgue se puede restaurar
+	EliminadoEn time.Time `bson:"eliminado_en,omitempty" json:"eliminado_en,omitzero"`
 }
@@ -0,0 +1,19 @@
+package models
+
+// Problema es el cuerpo de error según el RFC 7807 (application/problem+json)
+type Problema struct {
+	Tipo      string       `json:"type"`
+	Titulo    string       `json:"title"`
+	Estado    int          `json:"status"`
+	Detalle   string       `json:"detail,omitempty"`
+	Instancia string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errores   []ErrorCampo `json:"errors,omitempty"`
+}
+
+// ErrorCampo describe por qué un campo o parámetro fue rechazado
+type ErrorCampo struct {
+	Campo   string `json:"field"`
+	Regla   string `json:"rule,omitempty"`
+	Mensaje string `json:"message"`
+}
@@ -0,0 +1,30 @@
+package models
+
+// Estados de una dependencia y del servicio en los chequeos de salud
+const (
+	EstadoOK      = "ok"
+	EstadoError   = "error"
+	EstadoListo   = "listo"
+	EstadoNoListo = "no_listo"
+)
+
+// Compilacion identifica el binario que está corriendo
+type Compilacion struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Fecha   string `json:"fecha_compilacion,omitempty"`
+}
+
+// EstadoDependencia es el resultado de comprobar una dependencia
+type EstadoDependencia struct {
+	Estado     string `json:"estado"`
+	DuracionMs int64  `json:"duracion_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Salud es la respuesta de /healthz y /readyz
+type Salud struct {
+	Estado       string                       `json:"estado"`
+	Dependencias map[string]EstadoDependencia `json:"dependencias,omitempty"`
+	Compilacion  Compilacion                  `json:"compilacion"`
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var historial *mongo.Collection
+
+// SetColeccionHistorial inyecta la colección donde se registran los cambios de las personas
+func SetColeccionHistorial(c *mongo.Collection) {
+	historial = c
+}
+
+// RegistrarCambio agrega una entrada al historial de una persona
+func RegistrarCambio(ctx context.Context, cambio models.CambioPersona) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutOperacion)
+	defer cancel()
+
+	_, err := historial.InsertOne(ctx, cambio)
+	return err
+}
+
+// RegistrarCambios agrega con un solo InsertMany las entradas del historial de una
+// operación masiva. Sin orden, para que una entrada que falla no impida las demás
+func RegistrarCambios(ctx context.Context, cambios []models.CambioPersona) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutListado)
+	defer cancel()
+
+	documentos := make([]any, len(cambios))
+	for i, c := range cambios {
+		documentos[i] = c
+	}
+	_, err := historial.InsertMany(ctx, documentos, options.InsertMany().SetOrdered(false))
+	return err
+}
+
+// ObtenerHistorial devuelve los cambios de una persona del más antiguo al más reciente
+func ObtenerHistorial(ctx context.Context, tipo models.TipoDocumento, documento string) ([]models.CambioPersona, error) {
+	cambios := []models.CambioPersona{}
+	ctx, cancel := context.WithTimeout(ctx, timeoutListado)
+	defer cancel()
+
+	// El _id desempata los cambios registrados en el mismo milisegundo
+	opciones := options.Find().SetSort(bson.D{{Key: "fecha", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := historial.Find(ctx, filtroIdentidad(tipo, documento), opciones)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &cambios); err != nil {
+		return nil, err
+	}
+	return cambios, nil
+}
+
+type RealHistorialRepository struct{}
+
+func (r RealHistorialRepository) RegistrarCambio(ctx context.Context, cambio models.CambioPersona) error {
+	return RegistrarCambio(ctx, cambio)
+}
+
+func (r RealHistorialRepository) RegistrarCambios(ctx context.Context, cambios []models.CambioPersona) error {
+	return RegistrarCambios(ctx, cambios)
+}
+
+func (r RealHistorialRepository) ObtenerHistorial(ctx context.Context, tipo models.TipoDocumento, doc string) ([]models.CambioPersona, error) {
+	return ObtenerHistorial(ctx, tipo, doc)
+}
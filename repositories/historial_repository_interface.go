@@ -0,0 +1,13 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+)
+
+type HistorialRepository interface {
+	RegistrarCambio(ctx context.Context, cambio models.CambioPersona) error
+	RegistrarCambios(ctx context.Context, cambios []models.CambioPersona) error
+	ObtenerHistorial(ctx context.Context, tipo models.TipoDocumento, documento string) ([]models.CambioPersona, error)
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RetencionEliminadosPorDefecto es el tiempo que se conserva una persona eliminada antes de purgarla
+const RetencionEliminadosPorDefecto = 30 * 24 * time.Hour
+
+var retencionEliminados = RetencionEliminadosPorDefecto
+
+// SetRetencionEliminados define cuánto tiempo después de eliminada se purga una persona
+func SetRetencionEliminados(d time.Duration) {
+	retencionEliminados = d
+}
+
+// codigoIndiceConOtrasOpciones es el error de Mongo al crear un índice que ya existe con otras opciones
+const codigoIndiceConOtrasOpciones = 85
+
+const nombreIndicePurga = "purga_eliminados"
+
+// CrearIndices asegura los índices que necesita la colección de personas.
+// Se ejecuta al arrancar el servicio y es idempotente
+func CrearIndices(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	indices := []mongo.IndexModel{
+		{
+			// La identidad de la persona es única; evita duplicados aun con creaciones concurrentes
+			Keys:    bson.D{{Key: "tipo_documento", Value: 1}, {Key: "documento", Value: 1}},
+			Options: options.Index().SetName("identidad_unica").SetUnique(true),
+		},
+		{
+			// Índice de texto para la búsqueda; con el idioma español se ignoran tildes y mayúsculas
+			Keys: bson.D{{Key: "nombre", Value: "text"}, {Key: "apellido", Value: "text"}, {Key: "correo", Value: "text"}},
+			Options: options.Index().
+				SetName("busqueda_personas").
+				SetDefaultLanguage("spanish").
+				SetWeights(bson.D{{Key: "nombre", Value: 3}, {Key: "apellido", Value: 3}, {Key: "correo", Value: 1}}),
+		},
+	}
+	// Cada orden del listado desempata por _id; el mismo índice sirve en ambas direcciones
+	// y evita ordenar en memoria al saltar páginas o seguir el cursor
+	for _, campo := range models.CamposOrdenables {
+		indices = append(indices, mongo.IndexModel{
+			Keys:    bson.D{{Key: campo, Value: 1}, {Key: "_id", Value: 1}},
+			Options: options.Index().SetName("orden_" + campo),
+		})
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indices)
+	if err != nil {
+		return err
+	}
+
+	return asegurarPurga(ctx)
+}
+
+// asegurarPurga crea el índice TTL que borra las personas eliminadas al cumplirse la
+// retención. Si la retención cambió se ajusta el índice existente con collMod
+func asegurarPurga(ctx context.Context) error {
+	segundos := int32(retencionEliminados / time.Second)
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "eliminado_en", Value: 1}},
+		Options: options.Index().SetName(nombreIndicePurga).SetExpireAfterSeconds(segundos),
+	})
+
+	var errComando mongo.CommandError
+	if errors.As(err, &errComando) && errComando.Code == codigoIndiceConOtrasOpciones {
+		return collection.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: collection.Name()},
+			{Key: "index", Value: bson.D{{Key: "name", Value: nombreIndicePurga}, {Key: "expireAfterSeconds", Value: segundos}}},
+		}).Err()
+	}
+	return err
+}
+
+// CrearIndicesHistorial asegura el índice con el que se consulta el historial de cada persona
+func CrearIndicesHistorial(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	_, err := historial.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tipo_documento", Value: 1}, {Key: "documento", Value: 1}, {Key: "fecha", Value: 1}},
+		Options: options.Index().SetName("historial_persona"),
+	})
+	return err
+}
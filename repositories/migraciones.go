@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrarPersonas completa los campos que no existían cuando se guardaron los
+// registros más antiguos. Se ejecuta al arrancar y es idempotente
+func MigrarPersonas(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	// Antes del tipo de documento todas las personas se registraban con cédula
+	resultado, err := collection.UpdateMany(ctx,
+		bson.M{"tipo_documento": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"tipo_documento": models.TipoDocumentoPorDefecto}},
+	)
+	if err != nil {
+		return err
+	}
+	if resultado.ModifiedCount > 0 {
+		log.Printf("🔧 %d personas migradas al tipo de documento %s", resultado.ModifiedCount, models.TipoDocumentoPorDefecto)
+	}
+
+	// La versión se usa para el control de concurrencia optimista
+	resultado, err = collection.UpdateMany(ctx,
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": models.VersionInicial}},
+	)
+	if err != nil {
+		return err
+	}
+	if resultado.ModifiedCount > 0 {
+		log.Printf("🔧 %d personas migradas a la versión %d", resultado.ModifiedCount, models.VersionInicial)
+	}
+
+	// La fecha de creación de los registros antiguos se toma del _id, que la incluye
+	resultado, err = collection.UpdateMany(ctx,
+		bson.M{"creado_en": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"creado_en": bson.M{"$toDate": "$_id"}}}}},
+	)
+	if err != nil {
+		return err
+	}
+	if resultado.ModifiedCount > 0 {
+		log.Printf("🔧 %d personas migradas con su fecha de creación", resultado.ModifiedCount)
+	}
+
+	return nil
+}
+
+// MigrarTelefonos lleva al formato E.164 los teléfonos que se guardaron antes de
+// normalizarlos, para que el filtro por prefijo los encuentre. normalizar es la misma
+// regla que se aplica al guardar una persona; los teléfonos que no puede interpretar
+// se dejan como están. Cada persona migrada incrementa su versión, porque su
+// representación cambia. Se ejecuta al arrancar y es idempotente
+func MigrarTelefonos(ctx context.Context, normalizar func(string) (string, bool)) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	opciones := options.Find().SetProjection(bson.M{"telefono": 1}).SetBatchSize(tamanoTandaRecorrido)
+	cursor, err := collection.Find(ctx, bson.M{"telefono": bson.M{"$type": "string", "$not": primitive.Regex{Pattern: `^\+[0-9]+$`}}}, opciones)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var migradas, invalidas int64
+	var tanda []mongo.WriteModel
+	escribir := func() error {
+		if len(tanda) == 0 {
+			return nil
+		}
+		resultado, err := collection.BulkWrite(ctx, tanda, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
+		}
+		migradas += resultado.ModifiedCount
+		tanda = tanda[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var documento struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Telefono string             `bson:"telefono"`
+		}
+		if err := cursor.Decode(&documento); err != nil {
+			return err
+		}
+		telefono, ok := normalizar(documento.Telefono)
+		if !ok {
+			invalidas++
+			continue
+		}
+		// El filtro por el valor leído evita pisar un teléfono que cambió mientras tanto
+		tanda = append(tanda, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": documento.ID, "telefono": documento.Telefono}).
+			SetUpdate(bson.M{"$set": bson.M{"telefono": telefono}, "$inc": bson.M{"version": 1}}))
+		if len(tanda) == tamanoTandaRecorrido {
+			if err := escribir(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if err := escribir(); err != nil {
+		return err
+	}
+
+	if migradas > 0 {
+		log.Printf("🔧 %d personas migradas con el teléfono en formato E.164", migradas)
+	}
+	if invalidas > 0 {
+		log.Printf("⚠️ %d personas tienen un teléfono que no se pudo llevar a formato E.164", invalidas)
+	}
+	return nil
+}
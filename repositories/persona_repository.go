@@ -2,101 +2,531 @@ package repositories
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/danysoftdev/microservicio-go-mongodb/models"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var collection *mongo.Collection
 
+// Tiempos máximos por defecto de las operaciones sobre MongoDB
+const (
+	TimeoutOperacionPorDefecto = 5 * time.Second
+	TimeoutListadoPorDefecto   = 10 * time.Second
+)
+
+// tamanoTandaRecorrido es la cantidad de personas que trae el cursor de un
+// recorrido en cada viaje al servidor
+const tamanoTandaRecorrido = 500
+
+var (
+	timeoutOperacion = TimeoutOperacionPorDefecto
+	timeoutListado   = TimeoutListadoPorDefecto
+)
+
 // Permite inyectar la colección desde fuera (ideal para pruebas)
 func SetCollection(c *mongo.Collection) {
 	collection = c
 }
 
-// InsertarPersona guarda una nueva persona en la base de datos
-func InsertarPersona(persona models.Persona) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// SetTimeouts define el tiempo máximo de las operaciones sobre una persona y el de
+// los listados y búsquedas. Se aplican además de la cancelación del contexto recibido
+func SetTimeouts(operacion, listado time.Duration) {
+	timeoutOperacion = operacion
+	timeoutListado = listado
+}
+
+// InsertarPersona guarda una nueva persona en la base de datos y devuelve su _id,
+// que genera el driver si la persona no lo trae
+func InsertarPersona(ctx context.Context, persona models.Persona) (primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutOperacion)
+	defer cancel()
+
+	resultado, err := collection.InsertOne(ctx, persona)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	id, ok := resultado.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, fmt.Errorf("el _id insertado no es un ObjectID: %v", resultado.InsertedID)
+	}
+	return id, nil
+}
+
+// InsertarPersonas guarda un lote de personas con un solo InsertMany. Sin atomicidad
+// la inserción no es ordenada: cada persona se intenta aunque otras fallen y los
+// fallos llegan en un mongo.BulkWriteException con la posición de cada una. Con
+// atomicidad el lote se inserta en una transacción, que requiere un replica set
+func InsertarPersonas(ctx context.Context, personas []models.Persona, atomica bool) error {
+	// Un lote puede traer miles de personas, así que tiene el tiempo de un listado
+	ctx, cancel := context.WithTimeout(ctx, timeoutListado)
 	defer cancel()
 
-	_, err := collection.InsertOne(ctx, persona)
+	documentos := make([]any, len(personas))
+	for i, p := range personas {
+		documentos[i] = p
+	}
+
+	if !atomica {
+		_, err := collection.InsertMany(ctx, documentos, options.InsertMany().SetOrdered(false))
+		return err
+	}
+
+	sesion, err := collection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer sesion.EndSession(ctx)
+
+	_, err = sesion.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return collection.InsertMany(sc, documentos)
+	})
 	return err
 }
 
-// ObtenerPersonas devuelve una lista de todas las personas
-func ObtenerPersonas() ([]models.Persona, error) {
-	var personas []models.Persona
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// ObtenerPersonasPaginadas devuelve una página de personas ordenada según la consulta.
+// Si la consulta trae un cursor (Despues) se continúa a partir de esa persona,
+// de lo contrario se usa la página indicada.
+func ObtenerPersonasPaginadas(ctx context.Context, consulta models.ConsultaPersonas) (models.PaginaPersonas, error) {
+	pagina := models.PaginaPersonas{Pagina: consulta.Pagina, Limite: consulta.Limite}
+	ctx, cancel := context.WithTimeout(ctx, timeoutListado)
 	defer cancel()
 
-	cursor, err := collection.Find(ctx, bson.M{})
+	filtro := filtroPersonas(consulta.Filtro)
+
+	total, err := collection.CountDocuments(ctx, filtro)
 	if err != nil {
-		return nil, err
+		return pagina, err
+	}
+	pagina.Total = total
+
+	direccion := 1
+	if consulta.Descendente {
+		direccion = -1
+	}
+
+	orden := bson.D{{Key: "_id", Value: direccion}}
+	if consulta.Orden != "" {
+		orden = bson.D{{Key: consulta.Orden, Value: direccion}, {Key: "_id", Value: direccion}}
+	}
+
+	// Se pide un elemento extra para saber si existe una página siguiente
+	opciones := options.Find().SetSort(orden).SetLimit(int64(consulta.Limite) + 1)
+
+	if consulta.Despues != "" {
+		filtroCursor, err := filtroDespuesDe(ctx, consulta, direccion)
+		if err != nil {
+			return pagina, err
+		}
+		filtro = bson.M{"$and": bson.A{filtro, filtroCursor}}
+		pagina.Pagina = 0
+	} else if consulta.Pagina > 1 {
+		opciones.SetSkip(int64(consulta.Pagina-1) * int64(consulta.Limite))
+	}
+
+	cursor, err := collection.Find(ctx, filtro, opciones)
+	if err != nil {
+		return pagina, err
+	}
+	defer cursor.Close(ctx)
+
+	pagina.Datos = []models.Persona{}
+	if err := cursor.All(ctx, &pagina.Datos); err != nil {
+		return pagina, err
+	}
+
+	if len(pagina.Datos) > consulta.Limite {
+		pagina.Datos = pagina.Datos[:consulta.Limite]
+		pagina.SiguienteCursor = pagina.Datos[len(pagina.Datos)-1].ID.Hex()
+	}
+
+	return pagina, nil
+}
+
+// RecorrerPersonas entrega una a una, en orden de _id, las personas que cumplen el
+// filtro sin cargarlas todas en memoria. El recorrido no tiene el tiempo máximo de
+// los listados porque depende de qué tan rápido consuma el llamador: solo termina
+// cuando se cancela el contexto o cuando visitar devuelve un error
+func RecorrerPersonas(ctx context.Context, filtro models.FiltroPersonas, visitar func(models.Persona) error) error {
+	opciones := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetBatchSize(tamanoTandaRecorrido)
+
+	cursor, err := collection.Find(ctx, filtroPersonas(filtro), opciones)
+	if err != nil {
+		return err
 	}
+	defer cursor.Close(ctx)
 
 	for cursor.Next(ctx) {
 		var persona models.Persona
 		if err := cursor.Decode(&persona); err != nil {
-			return nil, err
+			return err
+		}
+		if err := visitar(persona); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// filtroPersonas traduce los criterios del listado a un filtro de MongoDB.
+// Los textos se escapan para que se comparen de forma literal y las personas
+// eliminadas se excluyen salvo que se pidan explícitamente
+func filtroPersonas(f models.FiltroPersonas) bson.M {
+	filtro := bson.M{}
+
+	if !f.IncluirEliminados {
+		filtro["eliminado_en"] = nil
+	}
+
+	if f.Apellido != "" {
+		filtro["apellido"] = bson.M{"$regex": "^" + regexp.QuoteMeta(f.Apellido) + "$", "$options": "i"}
+	}
+	if f.DominioCorreo != "" {
+		filtro["correo"] = bson.M{"$regex": "@" + regexp.QuoteMeta(f.DominioCorreo) + "$", "$options": "i"}
+	}
+	if f.EdadMin != nil || f.EdadMax != nil {
+		edad := bson.M{}
+		if f.EdadMin != nil {
+			edad["$gte"] = *f.EdadMin
+		}
+		if f.EdadMax != nil {
+			edad["$lte"] = *f.EdadMax
 		}
-		personas = append(personas, persona)
+		filtro["edad"] = edad
+	}
+	if f.PrefijoTelefono != "" {
+		filtro["telefono"] = bson.M{"$regex": "^" + regexp.QuoteMeta(f.PrefijoTelefono)}
+	}
+	if f.Direccion != "" {
+		filtro["direccion"] = bson.M{"$regex": regexp.QuoteMeta(f.Direccion), "$options": "i"}
+	}
+
+	return filtro
+}
+
+// ActualizarPersonas aplica la actualización con un solo BulkWrite que tiene un UpdateOne
+// por persona, filtrado por su _id, la versión leída y el filtro de la operación. Así
+// no se pisan los cambios que otra operación hizo después de la lectura. Devuelve los
+// _id de las personas que se modificaron.
+// Los cambios se asignan como valores literales, los reemplazos se hacen sobre el
+// texto guardado y la versión de cada persona se incrementa en uno
+func ActualizarPersonas(ctx context.Context, filtro models.FiltroPersonas, versiones map[primitive.ObjectID]int64, actualizacion models.ActualizacionMasiva) ([]primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutListado)
+	defer cancel()
+
+	asignaciones := bson.M{"version": bson.M{"$add": bson.A{"$version", 1}}}
+	// En un pipeline un texto que empieza con $ se leería como un campo, así que los
+	// valores del cliente van siempre como literales
+	for campo, valor := range actualizacion.Cambios {
+		asignaciones[campo] = bson.M{"$literal": valor}
+	}
+	for campo, r := range actualizacion.Reemplazos {
+		asignaciones[campo] = bson.M{"$replaceAll": bson.M{
+			"input":       "$" + campo,
+			"find":        bson.M{"$literal": r.Buscar},
+			"replacement": bson.M{"$literal": r.Reemplazo},
+		}}
+	}
+	pipeline := mongo.Pipeline{{{Key: "$set", Value: asignaciones}}}
+
+	operaciones := make([]mongo.WriteModel, 0, len(versiones))
+	for id, version := range versiones {
+		seleccion := filtroPersonas(filtro)
+		seleccion["_id"] = id
+		seleccion["version"] = version
+		operaciones = append(operaciones, mongo.NewUpdateOneModel().SetFilter(seleccion).SetUpdate(pipeline))
+	}
+
+	resultado, err := collection.BulkWrite(ctx, operaciones, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return nil, err
+	}
+	if resultado.MatchedCount == int64(len(versiones)) {
+		return slices.Collect(maps.Keys(versiones)), nil
+	}
+
+	// El BulkWrite solo informa cuántas coincidieron. Las modificadas son las que
+	// quedaron con la versión siguiente a la leída y la fecha de actualización del lote
+	escritas := bson.A{}
+	for id, version := range versiones {
+		escrita := bson.M{"_id": id, "version": version + 1}
+		if fecha, ok := actualizacion.Cambios["actualizado_en"]; ok {
+			escrita["actualizado_en"] = fecha
+		}
+		escritas = append(escritas, escrita)
+	}
+	return idsDe(ctx, bson.M{"$or": escritas})
+}
+
+// PurgarPersonas borra definitivamente, con un solo BulkWrite que tiene un DeleteOne por
+// persona, las personas indicadas que siguen eliminadas, en la versión leída y dentro
+// del filtro. Devuelve los _id de las que se borraron
+func PurgarPersonas(ctx context.Context, filtro models.FiltroPersonas, versiones map[primitive.ObjectID]int64) ([]primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutListado)
+	defer cancel()
+
+	operaciones := make([]mongo.WriteModel, 0, len(versiones))
+	ids := make([]primitive.ObjectID, 0, len(versiones))
+	for id, version := range versiones {
+		seleccion := filtroPersonas(filtro)
+		seleccion["eliminado_en"] = bson.M{"$ne": nil}
+		seleccion["_id"] = id
+		seleccion["version"] = version
+		operaciones = append(operaciones, mongo.NewDeleteOneModel().SetFilter(seleccion))
+		ids = append(ids, id)
+	}
+
+	resultado, err := collection.BulkWrite(ctx, operaciones, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return nil, err
+	}
+	if resultado.DeletedCount == int64(len(versiones)) {
+		return ids, nil
+	}
+
+	// Las borradas son las que ya no están guardadas
+	quedan, err := idsDe(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	guardadas := make(map[primitive.ObjectID]bool, len(quedan))
+	for _, id := range quedan {
+		guardadas[id] = true
+	}
+	return slices.DeleteFunc(ids, func(id primitive.ObjectID) bool { return guardadas[id] }), nil
+}
+
+// idsDe devuelve el _id de las personas que cumplen el filtro
+func idsDe(ctx context.Context, filtro bson.M) ([]primitive.ObjectID, error) {
+	cursor, err := collection.Find(ctx, filtro, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var documentos []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &documentos); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, len(documentos))
+	for i, d := range documentos {
+		ids[i] = d.ID
+	}
+	return ids, nil
+}
+
+// filtroDespuesDe construye el filtro que ubica los documentos posteriores al cursor,
+// respetando el campo de orden y usando el _id como desempate
+func filtroDespuesDe(ctx context.Context, consulta models.ConsultaPersonas, direccion int) (bson.M, error) {
+	id, err := primitive.ObjectIDFromHex(consulta.Despues)
+	if err != nil {
+		return nil, err
+	}
+
+	operador := "$gt"
+	if direccion < 0 {
+		operador = "$lt"
+	}
+
+	if consulta.Orden == "" {
+		return bson.M{"_id": bson.M{operador: id}}, nil
+	}
+
+	var referencia bson.M
+	err = collection.FindOne(ctx, bson.M{"_id": id}).Decode(&referencia)
+	if err != nil {
+		return nil, err
+	}
+
+	valor := referencia[consulta.Orden]
+	return bson.M{"$or": bson.A{
+		bson.M{consulta.Orden: bson.M{operador: valor}},
+		bson.M{consulta.Orden: valor, "_id": bson.M{operador: id}},
+	}}, nil
+}
+
+// BuscarPersonas hace una búsqueda de texto sobre nombre, apellido y correo.
+// Los resultados vienen ordenados por relevancia y la comparación ignora tildes y mayúsculas
+func BuscarPersonas(ctx context.Context, query string, limite int) ([]models.Persona, error) {
+	personas := []models.Persona{}
+	ctx, cancel := context.WithTimeout(ctx, timeoutListado)
+	defer cancel()
+
+	puntaje := bson.M{"$meta": "textScore"}
+	opciones := options.Find().
+		SetProjection(bson.M{"puntaje": puntaje}).
+		SetSort(bson.M{"puntaje": puntaje}).
+		SetLimit(int64(limite))
+
+	cursor, err := collection.Find(ctx, bson.M{"$text": bson.M{"$search": query}, "eliminado_en": nil}, opciones)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &personas); err != nil {
+		return nil, err
 	}
 
 	return personas, nil
 }
 
-// ObtenerPersonaPorDocumento busca una persona por su Documento
-func ObtenerPersonaPorDocumento(documento string) (models.Persona, error) {
+// filtroIdentidad ubica a una persona por el par (tipo de documento, número)
+func filtroIdentidad(tipo models.TipoDocumento, documento string) bson.M {
+	return bson.M{"tipo_documento": tipo, "documento": documento}
+}
+
+// ObtenerPersonaPorDocumento busca una persona por su tipo y número de documento.
+// Las personas eliminadas no se encuentran
+func ObtenerPersonaPorDocumento(ctx context.Context, tipo models.TipoDocumento, documento string) (models.Persona, error) {
 	var persona models.Persona
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, timeoutOperacion)
 	defer cancel()
 
-	err := collection.FindOne(ctx, bson.M{"documento": documento}).Decode(&persona)
+	filtro := filtroIdentidad(tipo, documento)
+	filtro["eliminado_en"] = nil
+
+	err := collection.FindOne(ctx, filtro).Decode(&persona)
 	return persona, err
 }
 
-// ActualizarPersona actualiza los datos de una persona por Documento
-func ActualizarPersona(documento string, persona models.Persona) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// ObtenerPersonaEliminada busca una persona eliminada que todavía no se ha purgado
+func ObtenerPersonaEliminada(ctx context.Context, tipo models.TipoDocumento, documento string) (models.Persona, error) {
+	var persona models.Persona
+	ctx, cancel := context.WithTimeout(ctx, timeoutOperacion)
+	defer cancel()
+
+	filtro := filtroIdentidad(tipo, documento)
+	filtro["eliminado_en"] = bson.M{"$ne": nil}
+
+	err := collection.FindOne(ctx, filtro).Decode(&persona)
+	return persona, err
+}
+
+// filtroVersion ubica a la persona solo si sigue en la versión que leyó el cliente,
+// de modo que dos escrituras concurrentes no se pisen
+func filtroVersion(tipo models.TipoDocumento, documento string, version int64) bson.M {
+	filtro := filtroIdentidad(tipo, documento)
+	filtro["version"] = version
+	return filtro
+}
+
+// ActualizarPersona reemplaza los datos de una persona si sigue en la versión
+// indicada. Si otra operación la cambió antes devuelve mongo.ErrNoDocuments
+func ActualizarPersona(ctx context.Context, tipo models.TipoDocumento, documento string, version int64, persona models.Persona) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutOperacion)
 	defer cancel()
 
 	update := bson.M{
 		"$set": persona,
 	}
 
-	_, err := collection.UpdateOne(ctx, bson.M{"documento": documento}, update)
-	return err
+	resultado, err := collection.UpdateOne(ctx, filtroVersion(tipo, documento, version), update)
+	if err != nil {
+		return err
+	}
+	if resultado.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
 }
 
-// EliminarPersona elimina una persona por su Documento
-func EliminarPersona(documento string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// ActualizarCampos modifica solo los campos indicados, sin reescribir el resto del
+// documento, con la misma condición de versión que ActualizarPersona
+func ActualizarCampos(ctx context.Context, tipo models.TipoDocumento, documento string, version int64, cambios map[string]any) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutOperacion)
 	defer cancel()
 
-	_, err := collection.DeleteOne(ctx, bson.M{"documento": documento})
-	return err
+	update := bson.M{
+		"$set": cambios,
+	}
+
+	resultado, err := collection.UpdateOne(ctx, filtroVersion(tipo, documento, version), update)
+	if err != nil {
+		return err
+	}
+	if resultado.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// PurgarPersona borra definitivamente una persona que ya fue eliminada. Si la
+// persona no existe o no está eliminada devuelve mongo.ErrNoDocuments
+func PurgarPersona(ctx context.Context, tipo models.TipoDocumento, documento string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutOperacion)
+	defer cancel()
+
+	filtro := filtroIdentidad(tipo, documento)
+	filtro["eliminado_en"] = bson.M{"$ne": nil}
+
+	resultado, err := collection.DeleteOne(ctx, filtro)
+	if err != nil {
+		return err
+	}
+	if resultado.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
 }
 
 type RealPersonaRepository struct{}
 
-func (r RealPersonaRepository) InsertarPersona(p models.Persona) error {
-	return InsertarPersona(p)
+func (r RealPersonaRepository) InsertarPersona(ctx context.Context, p models.Persona) (primitive.ObjectID, error) {
+	return InsertarPersona(ctx, p)
 }
 
-func (r RealPersonaRepository) ObtenerPersonas() ([]models.Persona, error) {
-	return ObtenerPersonas()
+func (r RealPersonaRepository) InsertarPersonas(ctx context.Context, personas []models.Persona, atomica bool) error {
+	return InsertarPersonas(ctx, personas, atomica)
 }
 
-func (r RealPersonaRepository) ObtenerPersonaPorDocumento(doc string) (models.Persona, error) {
-	return ObtenerPersonaPorDocumento(doc)
+func (r RealPersonaRepository) ObtenerPersonasPaginadas(ctx context.Context, consulta models.ConsultaPersonas) (models.PaginaPersonas, error) {
+	return ObtenerPersonasPaginadas(ctx, consulta)
 }
 
-func (r RealPersonaRepository) ActualizarPersona(doc string, p models.Persona) error {
-	return ActualizarPersona(doc, p)
+func (r RealPersonaRepository) RecorrerPersonas(ctx context.Context, filtro models.FiltroPersonas, visitar func(models.Persona) error) error {
+	return RecorrerPersonas(ctx, filtro, visitar)
 }
 
-func (r RealPersonaRepository) EliminarPersona(doc string) error {
-	return EliminarPersona(doc)
-}
\ No newline at end of file
+func (r RealPersonaRepository) BuscarPersonas(ctx context.Context, query string, limite int) ([]models.Persona, error) {
+	return BuscarPersonas(ctx, query, limite)
+}
+
+func (r RealPersonaRepository) ObtenerPersonaPorDocumento(ctx context.Context, tipo models.TipoDocumento, doc string) (models.Persona, error) {
+	return ObtenerPersonaPorDocumento(ctx, tipo, doc)
+}
+
+func (r RealPersonaRepository) ActualizarPersona(ctx context.Context, tipo models.TipoDocumento, doc string, version int64, p models.Persona) error {
+	return ActualizarPersona(ctx, tipo, doc, version, p)
+}
+
+func (r RealPersonaRepository) ActualizarCampos(ctx context.Context, tipo models.TipoDocumento, doc string, version int64, cambios map[string]any) error {
+	return ActualizarCampos(ctx, tipo, doc, version, cambios)
+}
+
+func (r RealPersonaRepository) ObtenerPersonaEliminada(ctx context.Context, tipo models.TipoDocumento, doc string) (models.Persona, error) {
+	return ObtenerPersonaEliminada(ctx, tipo, doc)
+}
+
+func (r RealPersonaRepository) PurgarPersona(ctx context.Context, tipo models.TipoDocumento, doc string) error {
+	return PurgarPersona(ctx, tipo, doc)
+}
+
+func (r RealPersonaRepository) ActualizarPersonas(ctx context.Context, filtro models.FiltroPersonas, versiones map[primitive.ObjectID]int64, actualizacion models.ActualizacionMasiva) ([]primitive.ObjectID, error) {
+	return ActualizarPersonas(ctx, filtro, versiones, actualizacion)
+}
+
+func (r RealPersonaRepository) PurgarPersonas(ctx context.Context, filtro models.FiltroPersonas, versiones map[primitive.ObjectID]int64) ([]primitive.ObjectID, error) {
+	return PurgarPersonas(ctx, filtro, versiones)
+}
@@ -1,11 +1,23 @@
 package repositories
 
-import "github.com/danysoftdev/microservicio-go-mongodb/models"
+import (
+	"context"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type PersonaRepository interface {
-	InsertarPersona(persona models.Persona) error
-	ObtenerPersonas() ([]models.Persona, error)
-	ObtenerPersonaPorDocumento(documento string) (models.Persona, error)
-	ActualizarPersona(documento string, persona models.Persona) error
-	EliminarPersona(documento string) error
+	InsertarPersona(ctx context.Context, persona models.Persona) (primitive.ObjectID, error)
+	InsertarPersonas(ctx context.Context, personas []models.Persona, atomica bool) error
+	ObtenerPersonasPaginadas(ctx context.Context, consulta models.ConsultaPersonas) (models.PaginaPersonas, error)
+	RecorrerPersonas(ctx context.Context, filtro models.FiltroPersonas, visitar func(models.Persona) error) error
+	BuscarPersonas(ctx context.Context, query string, limite int) ([]models.Persona, error)
+	ObtenerPersonaPorDocumento(ctx context.Context, tipo models.TipoDocumento, documento string) (models.Persona, error)
+	ActualizarPersona(ctx context.Context, tipo models.TipoDocumento, documento string, version int64, persona models.Persona) error
+	ActualizarCampos(ctx context.Context, tipo models.TipoDocumento, documento string, version int64, cambios map[string]any) error
+	ObtenerPersonaEliminada(ctx context.Context, tipo models.TipoDocumento, documento string) (models.Persona, error)
+	PurgarPersona(ctx context.Context, tipo models.TipoDocumento, documento string) error
+	ActualizarPersonas(ctx context.Context, filtro models.FiltroPersonas, versiones map[primitive.ObjectID]int64, actualizacion models.ActualizacionMasiva) ([]primitive.ObjectID, error)
+	PurgarPersonas(ctx context.Context, filtro models.FiltroPersonas, versiones map[primitive.ObjectID]int64) ([]primitive.ObjectID, error)
 }
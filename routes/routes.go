@@ -0,0 +1,148 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/controllers"
+	"github.com/danysoftdev/microservicio-go-mongodb/metricas"
+	"github.com/danysoftdev/microservicio-go-mongodb/middlewares"
+
+	"github.com/gorilla/mux"
+)
+
+// PrefijoAPI es el prefijo de la versión actual de la API
+const PrefijoAPI = "/api/v1"
+
+// RutaPersonas es la ruta del recurso de personas
+const RutaPersonas = PrefijoAPI + "/personas"
+
+// Fechas en que las rutas anteriores a la API versionada quedaron obsoletas y en que
+// se retirarán. Se anuncian en las cabeceras Deprecation (RFC 9745) y Sunset (RFC 8594)
+var (
+	ObsolescenciaRutasAnteriores = time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)
+	RetiroRutasAnteriores        = time.Date(2027, time.May, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// ruta asocia un método y una ruta con su controlador
+type ruta struct {
+	metodo      string
+	plantilla   string
+	controlador http.HandlerFunc
+}
+
+// rutasPersonas son las rutas del recurso de personas, relativas a RutaPersonas. Las
+// rutas fijas van antes que /{documento} para que no se tomen como un documento
+var rutasPersonas = []ruta{
+	{"POST", "", controllers.CrearPersona},
+	{"GET", "", controllers.ObtenerPersonas},
+	{"POST", "/bulk", controllers.CrearPersonas},
+	{"POST", "/import", controllers.ImportarPersonas},
+	{"GET", "/export", controllers.ExportarPersonas},
+	{"GET", "/search", controllers.BuscarPersonas},
+	{"GET", "/{documento}", controllers.ObtenerPersonaPorDocumento},
+	{"PUT", "/{documento}", controllers.ActualizarPersona},
+	{"PATCH", "/{documento}", controllers.ParchearPersona},
+	{"DELETE", "/{documento}", controllers.EliminarPersona},
+	{"POST", "/{documento}/restaurar", controllers.RestaurarPersona},
+	{"GET", "/{documento}/historial", controllers.ObtenerHistorial},
+	{"GET", "/{documento}/historial/estado", controllers.ObtenerPersonaEnFecha},
+}
+
+// rutasAdministracion son las operaciones de administración, relativas a PrefijoAPI.
+// El gateway solo debe exponer las rutas /admin a los administradores
+var rutasAdministracion = []ruta{
+	{"DELETE", "/admin/personas/{documento}", controllers.PurgarPersona},
+	{"PATCH", "/admin/personas", controllers.ParchearPersonas},
+	{"DELETE", "/admin/personas", controllers.EliminarPersonas},
+}
+
+// rutaAnterior es una ruta previa a la API versionada que se mantiene como alias
+// obsoleto de su sucesora en PrefijoAPI
+type rutaAnterior struct {
+	ruta
+	sucesora string
+}
+
+var rutasAnteriores = []rutaAnterior{
+	{ruta{"POST", "/crear-personas", controllers.CrearPersona}, RutaPersonas},
+	{ruta{"GET", "/listar-personas", controllers.ObtenerPersonas}, RutaPersonas},
+	{ruta{"POST", "/personas/bulk", controllers.CrearPersonas}, RutaPersonas + "/bulk"},
+	{ruta{"POST", "/personas/import", controllers.ImportarPersonas}, RutaPersonas + "/import"},
+	{ruta{"GET", "/personas/export", controllers.ExportarPersonas}, RutaPersonas + "/export"},
+	{ruta{"GET", "/personas/search", controllers.BuscarPersonas}, RutaPersonas + "/search"},
+	{ruta{"GET", "/buscar-personas/{documento}", controllers.ObtenerPersonaPorDocumento}, RutaPersonas + "/{documento}"},
+	{ruta{"PUT", "/actualizar-personas/{documento}", controllers.ActualizarPersona}, RutaPersonas + "/{documento}"},
+	{ruta{"PATCH", "/personas/{documento}", controllers.ParchearPersona}, RutaPersonas + "/{documento}"},
+	{ruta{"DELETE", "/eliminar-personas/{documento}", controllers.EliminarPersona}, RutaPersonas + "/{documento}"},
+	{ruta{"POST", "/personas/{documento}/restaurar", controllers.RestaurarPersona}, RutaPersonas + "/{documento}/restaurar"},
+	{ruta{"GET", "/personas/{documento}/historial", controllers.ObtenerHistorial}, RutaPersonas + "/{documento}/historial"},
+	{ruta{"GET", "/personas/{documento}/historial/estado", controllers.ObtenerPersonaEnFecha}, RutaPersonas + "/{documento}/historial/estado"},
+	{ruta{"DELETE", "/admin/personas/{documento}", controllers.PurgarPersona}, PrefijoAPI + "/admin/personas/{documento}"},
+	{ruta{"PATCH", "/admin/personas", controllers.ParchearPersonas}, PrefijoAPI + "/admin/personas"},
+	{ruta{"DELETE", "/admin/personas", controllers.EliminarPersonas}, PrefijoAPI + "/admin/personas"},
+}
+
+// NuevoRouter arma el enrutador del servicio con sus middlewares, el recurso
+// versionado de personas y los alias obsoletos de las rutas anteriores
+func NuevoRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Use(middlewares.Metricas)
+	router.Use(middlewares.RequestID)
+	router.Use(middlewares.Usuario)
+
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Hello World")
+	})
+
+	// Sondas del orquestador: /healthz indica que el proceso vive y /readyz que sus
+	// dependencias responden y puede recibir tráfico
+	router.HandleFunc("/healthz", controllers.Vivo).Methods("GET")
+	router.HandleFunc("/readyz", controllers.Listo).Methods("GET")
+	router.Handle("/metrics", metricas.Handler()).Methods("GET")
+
+	controllers.SetRutaPersonas(RutaPersonas)
+	RegistrarPersonas(router.PathPrefix(PrefijoAPI).Subrouter())
+	registrarRutasAnteriores(router)
+
+	return router
+}
+
+// RegistrarPersonas agrega al enrutador, que debe tener el prefijo PrefijoAPI, las
+// rutas del recurso de personas y las de administración
+func RegistrarPersonas(router *mux.Router) {
+	personas := router.PathPrefix("/personas").Subrouter()
+	for _, r := range rutasPersonas {
+		personas.HandleFunc(r.plantilla, r.controlador).Methods(r.metodo)
+	}
+	for _, r := range rutasAdministracion {
+		router.HandleFunc(r.plantilla, r.controlador).Methods(r.metodo)
+	}
+}
+
+// registrarRutasAnteriores atiende cada ruta anterior avisando en las cabeceras que
+// está obsoleta
+func registrarRutasAnteriores(router *mux.Router) {
+	for _, r := range rutasAnteriores {
+		router.HandleFunc(r.plantilla, obsoleta(r.sucesora, r.controlador)).Methods(r.metodo)
+	}
+}
+
+// obsoleta agrega a la respuesta las cabeceras Deprecation y Sunset, y un enlace a la
+// ruta sucesora con las mismas variables de la petición
+func obsoleta(sucesora string, controlador http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		destino := sucesora
+		for nombre, valor := range mux.Vars(r) {
+			destino = strings.ReplaceAll(destino, "{"+nombre+"}", url.PathEscape(valor))
+		}
+
+		w.Header().Set("Deprecation", fmt.Sprintf("@%d", ObsolescenciaRutasAnteriores.Unix()))
+		w.Header().Set("Sunset", RetiroRutasAnteriores.Format(http.TimeFormat))
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, destino))
+		controlador(w, r)
+	}
+}
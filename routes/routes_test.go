@@ -0,0 +1,104 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/routes"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const personaJSON = `{"tipo_documento": "TI", "documento": "1002003004", "nombre": "Juan", "apellido": "Pérez", "edad": 15, "correo": "juan@example.com", "telefono": "3001234567", "direccion": "Calle 123"}`
+
+func TestRecursoPersonas(t *testing.T) {
+	router := routes.NuevoRouter()
+
+	t.Run("Crear debe responder con la ubicación de la persona", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("InsertarPersona", mock.Anything, mock.Anything).Return(primitive.NewObjectID(), nil)
+
+		req := httptest.NewRequest("POST", "/api/v1/personas", strings.NewReader(personaJSON))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, "/api/v1/personas/1002003004?tipo_documento=TI", rr.Header().Get("Location"))
+		assert.Empty(t, rr.Header().Get("Deprecation"))
+	})
+
+	t.Run("Las rutas fijas no se toman como un documento", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("BuscarPersonas", mock.Anything, "juan", services.LimitePorDefecto).Return([]models.Persona{}, nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/personas/search?q=juan", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Cada método del documento debe llegar a su controlador", func(t *testing.T) {
+		id := primitive.NewObjectID()
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{ID: id, Documento: "123", Version: 1}, nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/personas/123", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `"`+id.Hex()+`-1"`, rr.Header().Get("ETag"))
+
+		// Sin If-Match las escrituras se rechazan antes de tocar el repositorio
+		for _, metodo := range []string{"PUT", "PATCH", "DELETE"} {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(metodo, "/api/v1/personas/123", nil))
+			assert.Equal(t, http.StatusPreconditionRequired, rr.Code, metodo)
+		}
+	})
+}
+
+func TestRutasAnteriores(t *testing.T) {
+	router := routes.NuevoRouter()
+
+	casos := []struct {
+		metodo, ruta, sucesora string
+	}{
+		{"PUT", "/actualizar-personas/123", "/api/v1/personas/123"},
+		{"PATCH", "/personas/123", "/api/v1/personas/123"},
+		{"DELETE", "/eliminar-personas/a%20b", "/api/v1/personas/a%20b"},
+		{"GET", "/personas/123/historial/estado?fecha=ayer", "/api/v1/personas/123/historial/estado"},
+		{"PATCH", "/admin/personas", "/api/v1/admin/personas"},
+	}
+
+	for _, caso := range casos {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(caso.metodo, caso.ruta, nil))
+
+		assert.NotEqual(t, http.StatusNotFound, rr.Code, caso.ruta)
+		assert.Equal(t, "@1793491200", rr.Header().Get("Deprecation"), caso.ruta)
+		assert.Equal(t, "Sat, 01 May 2027 00:00:00 GMT", rr.Header().Get("Sunset"), caso.ruta)
+		assert.Equal(t, `<`+caso.sucesora+`>; rel="successor-version"`, rr.Header().Get("Link"), caso.ruta)
+	}
+}
+
+func TestMetricas(t *testing.T) {
+	router := routes.NuevoRouter()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `http_peticiones_total{estado="200",metodo="GET",ruta="/healthz"}`)
+	assert.Contains(t, rr.Body.String(), `personas_operaciones_total{operacion="purgar"} 0`)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/contexto"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+)
+
+// UsuarioAnonimo se registra como autor cuando la petición no trae un usuario autenticado
+const UsuarioAnonimo = "anonimo"
+
+// Reloj entrega la hora actual. Se puede reemplazar para fijar las marcas de tiempo en las pruebas
+type Reloj func() time.Time
+
+var reloj Reloj = time.Now
+
+// SetReloj cambia la fuente de la hora con la que el servicio marca las escrituras
+func SetReloj(r Reloj) {
+	reloj = r
+}
+
+// ahora devuelve la hora en UTC con la precisión de milisegundos que guarda Mongo,
+// de modo que el valor devuelto al cliente coincida con el almacenado
+func ahora() time.Time {
+	return reloj().UTC().Truncate(time.Millisecond)
+}
+
+// actor devuelve el usuario que hace la operación
+func actor(ctx context.Context) string {
+	if usuario := contexto.Usuario(ctx); usuario != "" {
+		return usuario
+	}
+	return UsuarioAnonimo
+}
+
+// marcarCreacion asigna los datos de auditoría de una persona nueva. Una persona
+// nueva nunca está eliminada, aunque el cliente envíe eliminado_en
+func marcarCreacion(ctx context.Context, p *models.Persona) {
+	p.EliminadoEn = time.Time{}
+	p.CreadoEn = ahora()
+	p.CreadoPor = actor(ctx)
+	p.ActualizadoEn = p.CreadoEn
+	p.ActualizadoPor = p.CreadoPor
+}
+
+// conservarAuditoria copia los datos de auditoría y la marca de eliminación guardados,
+// descartando los que envió el cliente: solo BorrarPersona y RestaurarPersona cambian
+// eliminado_en
+func conservarAuditoria(p *models.Persona, actual models.Persona) {
+	p.EliminadoEn = actual.EliminadoEn
+	p.CreadoEn = actual.CreadoEn
+	p.CreadoPor = actual.CreadoPor
+	p.ActualizadoEn = actual.ActualizadoEn
+	p.ActualizadoPor = actual.ActualizadoPor
+}
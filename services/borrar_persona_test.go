@@ -1,6 +1,7 @@
 package services_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -8,19 +9,31 @@ import (
 	"github.com/danysoftdev/microservicio-go-mongodb/services"
 	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// marcaDeEliminacion son los campos que se guardan al eliminar una persona
+func marcaDeEliminacion(version int64) map[string]any {
+	return map[string]any{
+		"eliminado_en":    instante,
+		"version":         version,
+		"actualizado_en":  instante,
+		"actualizado_por": services.UsuarioAnonimo,
+	}
+}
+
 func TestBorrarPersona_Exito(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.Repo = mockRepo
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "123456").
-		Return(models.Persona{Documento: "123456"}, nil)
-	mockRepo.On("EliminarPersona", "123456").
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123456").
+		Return(models.Persona{Documento: "123456", Version: 2}, nil)
+	mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123456", int64(2), marcaDeEliminacion(3)).
 		Return(nil)
 
-	err := services.BorrarPersona("123456")
+	err := services.BorrarPersona(context.Background(), models.CC, "123456", services.VersionEsperada{Numero: 2})
 
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
@@ -30,7 +43,7 @@ func TestBorrarPersona_DocumentoVacio(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.Repo = mockRepo
 
-	err := services.BorrarPersona(" ")
+	err := services.BorrarPersona(context.Background(), models.CC, " ", services.VersionCualquiera)
 
 	assert.Error(t, err)
 	assert.Equal(t, "el documento no puede estar vacío", err.Error())
@@ -40,12 +53,12 @@ func TestBorrarPersona_NoEncontrada(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.Repo = mockRepo
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "000000").
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "000000").
 		Return(models.Persona{}, mongo.ErrNoDocuments)
 
-	err := services.BorrarPersona("000000")
+	err := services.BorrarPersona(context.Background(), models.CC, "000000", services.VersionCualquiera)
 
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, services.ErrNotFound)
 	assert.Equal(t, "persona no encontrada", err.Error())
 }
 
@@ -53,13 +66,57 @@ func TestBorrarPersona_ErrorEliminar(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.Repo = mockRepo
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "987654").
-		Return(models.Persona{Documento: "987654"}, nil)
-	mockRepo.On("EliminarPersona", "987654").
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "987654").
+		Return(models.Persona{Documento: "987654", Version: 2}, nil)
+	mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "987654", int64(2), marcaDeEliminacion(3)).
 		Return(errors.New("error al eliminar"))
 
-	err := services.BorrarPersona("987654")
+	err := services.BorrarPersona(context.Background(), models.CC, "987654", services.VersionEsperada{Numero: 2})
 
 	assert.Error(t, err)
 	assert.Equal(t, "error al eliminar", err.Error())
 }
+
+func TestBorrarPersona_VersionDesactualizada(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123456").
+		Return(models.Persona{Documento: "123456", Version: 3}, nil)
+
+	err := services.BorrarPersona(context.Background(), models.CC, "123456", services.VersionEsperada{Numero: 2})
+
+	assert.ErrorIs(t, err, services.ErrPreconditionFailed)
+	mockRepo.AssertNotCalled(t, "ActualizarCampos", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBorrarPersona_OtraPersonaConElMismoDocumento(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	// La persona que el cliente leyó se purgó y el documento se registró de nuevo:
+	// la versión coincide pero el registro no
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123456").
+		Return(models.Persona{ID: primitive.NewObjectID(), Documento: "123456", Version: 1}, nil)
+
+	err := services.BorrarPersona(context.Background(), models.CC, "123456", services.VersionEsperada{ID: primitive.NewObjectID(), Numero: 1})
+
+	assert.ErrorIs(t, err, services.ErrPreconditionFailed)
+	mockRepo.AssertNotCalled(t, "ActualizarCampos", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBorrarPersona_CambioConcurrente(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	// Otra operación cambia la persona entre la lectura y el borrado
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123456").
+		Return(models.Persona{Documento: "123456", Version: 3}, nil)
+	mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123456", int64(3), marcaDeEliminacion(4)).
+		Return(mongo.ErrNoDocuments)
+
+	err := services.BorrarPersona(context.Background(), models.CC, "123456", services.VersionCualquiera)
+
+	assert.ErrorIs(t, err, services.ErrPreconditionFailed)
+	mockRepo.AssertExpectations(t)
+}
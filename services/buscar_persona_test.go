@@ -1,6 +1,7 @@
 package services_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/danysoftdev/microservicio-go-mongodb/services"
 	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -16,18 +18,19 @@ func TestBuscarPersonaPorDocumento_Exito(t *testing.T) {
 	services.SetPersonaRepository(mockRepo)
 
 	personaMock := models.Persona{
-		Documento: "123",
-		Nombre:    "Juan",
-		Apellido:  "Pérez",
-		Edad:      30,
-		Correo:    "juan@example.com",
-		Telefono:  "1234567890",
-		Direccion: "Calle 123",
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Juan",
+		Apellido:      "Pérez",
+		Edad:          30,
+		Correo:        "juan@example.com",
+		Telefono:      "1234567890",
+		Direccion:     "Calle 123",
 	}
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(personaMock, nil)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(personaMock, nil)
 
-	persona, err := services.BuscarPersonaPorDocumento("123")
+	persona, err := services.BuscarPersonaPorDocumento(context.Background(), models.CC, "123")
 
 	assert.Nil(t, err)
 	assert.Equal(t, "Juan", persona.Nombre)
@@ -38,7 +41,7 @@ func TestBuscarPersonaPorDocumento_Vacio(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.SetPersonaRepository(mockRepo)
 
-	_, err := services.BuscarPersonaPorDocumento("")
+	_, err := services.BuscarPersonaPorDocumento(context.Background(), models.CC, "")
 
 	assert.NotNil(t, err)
 	assert.Equal(t, "el documento no puede estar vacío", err.Error())
@@ -48,11 +51,11 @@ func TestBuscarPersonaPorDocumento_NoEncontrado(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.SetPersonaRepository(mockRepo)
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "999").Return(models.Persona{}, mongo.ErrNoDocuments)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "999").Return(models.Persona{}, mongo.ErrNoDocuments)
 
-	_, err := services.BuscarPersonaPorDocumento("999")
+	_, err := services.BuscarPersonaPorDocumento(context.Background(), models.CC, "999")
 
-	assert.NotNil(t, err)
+	assert.ErrorIs(t, err, services.ErrNotFound)
 	assert.Equal(t, "persona no encontrada", err.Error())
 	mockRepo.AssertExpectations(t)
 }
@@ -61,11 +64,22 @@ func TestBuscarPersonaPorDocumento_ErrorBaseDeDatos(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.SetPersonaRepository(mockRepo)
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(models.Persona{}, errors.New("error de base de datos"))
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{}, errors.New("error de base de datos"))
 
-	_, err := services.BuscarPersonaPorDocumento("123")
+	_, err := services.BuscarPersonaPorDocumento(context.Background(), models.CC, "123")
 
-	assert.NotNil(t, err)
+	assert.ErrorIs(t, err, services.ErrInfrastructure)
 	assert.Equal(t, "error de base de datos", err.Error())
 	mockRepo.AssertExpectations(t)
 }
+
+func TestBuscarPersonaPorDocumento_TipoInvalido(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	_, err := services.BuscarPersonaPorDocumento(context.Background(), "XX", "123")
+
+	assert.ErrorIs(t, err, services.ErrValidation)
+	assert.Equal(t, "el tipo de documento debe ser CC, TI, CE, NIT o PA", err.Error())
+	mockRepo.AssertNotCalled(t, "ObtenerPersonaPorDocumento", mock.Anything, mock.Anything, mock.Anything)
+}
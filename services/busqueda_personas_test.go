@@ -0,0 +1,65 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBuscarPersonas_Exito(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	resultados := []models.Persona{{Documento: "1", Nombre: "José", Apellido: "Pérez"}}
+	mockRepo.On("BuscarPersonas", mock.Anything, "Jose Perez", 5).Return(resultados, nil)
+
+	personas, err := services.BuscarPersonas(context.Background(), "  Jose Perez ", 5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "José", personas[0].Nombre)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBuscarPersonas_AjustaLimite(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("BuscarPersonas", mock.Anything, "ana", services.LimitePorDefecto).Return([]models.Persona{}, nil).Once()
+	mockRepo.On("BuscarPersonas", mock.Anything, "ana", services.LimiteMaximo).Return([]models.Persona{}, nil).Once()
+
+	_, err := services.BuscarPersonas(context.Background(), "ana", 0)
+	assert.NoError(t, err)
+
+	_, err = services.BuscarPersonas(context.Background(), "ana", 1000)
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBuscarPersonas_Vacia(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	_, err := services.BuscarPersonas(context.Background(), "   ", 10)
+
+	assert.ErrorIs(t, err, services.ErrBusquedaVacia)
+	mockRepo.AssertNotCalled(t, "BuscarPersonas", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBuscarPersonas_ErrorBaseDeDatos(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	mockRepo.On("BuscarPersonas", mock.Anything, "ana", 10).Return([]models.Persona(nil), errors.New("error de base de datos"))
+
+	_, err := services.BuscarPersonas(context.Background(), "ana", 10)
+
+	assert.EqualError(t, err, "error de base de datos")
+	mockRepo.AssertExpectations(t)
+}
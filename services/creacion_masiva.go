@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/metricas"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaximoLote es la cantidad máxima de personas que se pueden crear en una sola petición,
+// y el tamaño de las tandas en que se escriben las operaciones masivas
+const MaximoLote = 5000
+
+// ResultadoCreacion es el desenlace de una persona dentro de una creación masiva:
+// el ID con el que quedó guardada o el error por el que no se creó
+type ResultadoCreacion struct {
+	ID  primitive.ObjectID
+	Err error
+}
+
+// CrearPersonas valida y guarda un lote de personas, devolviendo un resultado por
+// cada una en el mismo orden del lote. Sin atomicidad se guardan todas las válidas
+// aunque otras fallen; con atomicidad basta un fallo para no guardar ninguna, y las
+// que sí eran válidas se reportan con ErrLoteNoAplicado.
+// Solo se devuelve un error cuando no se sabe qué personas quedaron guardadas
+func CrearPersonas(ctx context.Context, personas []models.Persona, atomica bool) ([]ResultadoCreacion, error) {
+	if len(personas) > MaximoLote {
+		return nil, ErrLoteDemasiadoGrande
+	}
+
+	resultados := make([]ResultadoCreacion, len(personas))
+	validas := make([]models.Persona, 0, len(personas))
+	// posiciones guarda el lugar en el lote de cada persona válida
+	posiciones := make([]int, 0, len(personas))
+
+	for i, p := range personas {
+		p = NormalizarPersona(p)
+		if err := ValidarPersona(p); err != nil {
+			resultados[i].Err = err
+			continue
+		}
+
+		// El ID se asigna aquí para conocerlo aunque el lote falle a medias
+		p.ID = primitive.NewObjectID()
+		p.Version = models.VersionInicial
+		marcarCreacion(ctx, &p)
+		validas = append(validas, p)
+		posiciones = append(posiciones, i)
+	}
+
+	if len(validas) == 0 {
+		return resultados, nil
+	}
+	if atomica && len(validas) < len(personas) {
+		for _, i := range posiciones {
+			resultados[i].Err = ErrLoteNoAplicado
+		}
+		return resultados, nil
+	}
+
+	fallidas, err := erroresDeInsercion(Repo.InsertarPersonas(ctx, validas, atomica))
+	if err != nil {
+		return nil, errorInfraestructura(err)
+	}
+
+	var creadas []models.Persona
+	for j, p := range validas {
+		i := posiciones[j]
+		switch {
+		case fallidas[j] != nil:
+			resultados[i].Err = fallidas[j]
+		case atomica && len(fallidas) > 0:
+			resultados[i].Err = ErrLoteNoAplicado
+		default:
+			resultados[i].ID = p.ID
+			creadas = append(creadas, p)
+		}
+	}
+	metricas.ContarPersonas(models.OperacionCrear, int64(len(creadas)))
+	registrarCreaciones(ctx, creadas)
+	return resultados, nil
+}
+
+// erroresDeInsercion separa, por su posición en el lote, las personas que Mongo
+// rechazó. Cualquier otro error se devuelve tal cual, porque no dice qué se guardó
+func erroresDeInsercion(err error) (map[int]error, error) {
+	fallidas := map[int]error{}
+	if err == nil {
+		return fallidas, nil
+	}
+
+	var errLote mongo.BulkWriteException
+	if !errors.As(err, &errLote) || errLote.WriteConcernError != nil {
+		return nil, err
+	}
+	for _, e := range errLote.WriteErrors {
+		if mongo.IsDuplicateKeyError(e) {
+			fallidas[e.Index] = ErrDuplicate
+		} else {
+			fallidas[e.Index] = errorInfraestructura(e)
+		}
+	}
+	return fallidas, nil
+}
@@ -1,11 +1,17 @@
 package services_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"github.com/danysoftdev/microservicio-go-mongodb/contexto"
+	"github.com/danysoftdev/microservicio-go-mongodb/metricas"
 	"github.com/danysoftdev/microservicio-go-mongodb/models"
 	"github.com/danysoftdev/microservicio-go-mongodb/services"
 	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
@@ -17,21 +23,32 @@ func TestCrearPersonaExitosa(t *testing.T) {
 	services.Repo = mockRepo
 
 	persona := models.Persona{
-		Documento: "123",
-		Nombre:    "Ana",
-		Apellido:  "Díaz",
-		Edad:      25,
-		Correo:    "ana@example.com",
-		Telefono:  "1234567",
-		Direccion: "Calle Falsa 123",
+		TipoDocumento:  models.CC,
+		Documento:      "123",
+		Nombre:         "Ana",
+		Apellido:       "Díaz",
+		Edad:           25,
+		Correo:         "ana@example.com",
+		Telefono:       "+573001234567",
+		Direccion:      "Calle Falsa 123",
+		Version:        models.VersionInicial,
+		CreadoEn:       instante,
+		CreadoPor:      services.UsuarioAnonimo,
+		ActualizadoEn:  instante,
+		ActualizadoPor: services.UsuarioAnonimo,
 	}
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(models.Persona{}, errors.New("not found"))
-	mockRepo.On("InsertarPersona", persona).Return(nil)
+	id := primitive.NewObjectID()
+	mockRepo.On("InsertarPersona", mock.Anything, persona).Return(id, nil)
+	creadas := metricas.OperacionesPersonas.WithLabelValues(string(models.OperacionCrear))
+	antes := testutil.ToFloat64(creadas)
 
-	err := services.CrearPersona(persona)
+	creada, err := services.CrearPersona(context.Background(), persona)
 
 	assert.NoError(t, err)
+	persona.ID = id
+	assert.Equal(t, persona, creada)
+	assert.Equal(t, antes+1, testutil.ToFloat64(creadas))
 	mockRepo.AssertExpectations(t)
 }
 
@@ -41,18 +58,25 @@ func TestCrearPersonaYaExiste(t *testing.T) {
 	services.Repo = mockRepo
 
 	persona := models.Persona{
-		Documento: "123",
-		Nombre:    "Ana",
-		Apellido:  "Díaz",
-		Edad:      25,
-		Correo:    "ana@example.com",
-		Telefono:  "1234567",
-		Direccion: "Calle Falsa 123",
+		TipoDocumento:  models.CC,
+		Documento:      "123",
+		Nombre:         "Ana",
+		Apellido:       "Díaz",
+		Edad:           25,
+		Correo:         "ana@example.com",
+		Telefono:       "+573001234567",
+		Direccion:      "Calle Falsa 123",
+		Version:        models.VersionInicial,
+		CreadoEn:       instante,
+		CreadoPor:      services.UsuarioAnonimo,
+		ActualizadoEn:  instante,
+		ActualizadoPor: services.UsuarioAnonimo,
 	}
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(persona, nil)
+	mockRepo.On("InsertarPersona", mock.Anything, persona).Return(primitive.NilObjectID, mocks.ErrLlaveDuplicada)
 
-	err := services.CrearPersona(persona)
+	_, err := services.CrearPersona(context.Background(), persona)
+	assert.ErrorIs(t, err, services.ErrDuplicate)
 	assert.EqualError(t, err, "ya existe una persona con ese documento")
 }
 
@@ -61,26 +85,204 @@ func TestCrearPersonaConDatosInvalidos(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
 	services.Repo = mockRepo
 
-	mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(models.Persona{}, errors.New("not found"))
-
 	casos := []struct {
-		nombre         string
-		persona        models.Persona
-		errorEsperado  string
+		nombre        string
+		persona       models.Persona
+		errorEsperado string
 	}{
-		{"Documento vacío", models.Persona{Nombre: "Ana", Apellido: "Díaz", Edad: 25, Correo: "ana@example.com", Telefono: "123", Direccion: "Calle"}, "el documento no puede estar vacío"},
-		{"Nombre vacío", models.Persona{Documento: "123", Apellido: "Díaz", Edad: 25, Correo: "ana@example.com", Telefono: "123", Direccion: "Calle"}, "el nombre no puede estar vacío"},
-		{"Apellido vacío", models.Persona{Documento: "123", Nombre: "Ana", Edad: 25, Correo: "ana@example.com", Telefono: "123", Direccion: "Calle"}, "el apellido no puede estar vacío"},
-		{"Edad inválida", models.Persona{Documento: "123", Nombre: "Ana", Apellido: "Díaz", Edad: 0, Correo: "ana@example.com", Telefono: "123", Direccion: "Calle"}, "la edad debe ser un número entero mayor a 0"},
-		{"Correo inválido", models.Persona{Documento: "123", Nombre: "Ana", Apellido: "Díaz", Edad: 25, Correo: "anaexample.com", Telefono: "123", Direccion: "Calle"}, "el correo es inválido"},
+		{"Documento vacío", models.Persona{Nombre: "Ana", Apellido: "Díaz", Edad: 25, Correo: "ana@example.com", Telefono: "+573001234567", Direccion: "Calle"}, "el documento no puede estar vacío"},
+		{"Nombre vacío", models.Persona{Documento: "123", Apellido: "Díaz", Edad: 25, Correo: "ana@example.com", Telefono: "+573001234567", Direccion: "Calle"}, "el nombre no puede estar vacío"},
+		{"Apellido vacío", models.Persona{Documento: "123", Nombre: "Ana", Edad: 25, Correo: "ana@example.com", Telefono: "+573001234567", Direccion: "Calle"}, "el apellido no puede estar vacío"},
+		{"Edad inválida", models.Persona{Documento: "123", Nombre: "Ana", Apellido: "Díaz", Edad: 0, Correo: "ana@example.com", Telefono: "+573001234567", Direccion: "Calle"}, "la edad debe ser un número entero mayor a 0"},
+		{"Correo inválido", models.Persona{Documento: "123", Nombre: "Ana", Apellido: "Díaz", Edad: 25, Correo: "anaexample.com", Telefono: "+573001234567", Direccion: "Calle"}, "el correo es inválido"},
 		{"Teléfono vacío", models.Persona{Documento: "123", Nombre: "Ana", Apellido: "Díaz", Edad: 25, Correo: "ana@example.com", Telefono: "", Direccion: "Calle"}, "el teléfono no puede estar vacío"},
-		{"Dirección vacía", models.Persona{Documento: "123", Nombre: "Ana", Apellido: "Díaz", Edad: 25, Correo: "ana@example.com", Telefono: "123", Direccion: ""}, "la dirección no puede estar vacía"},
+		{"Dirección vacía", models.Persona{Documento: "123", Nombre: "Ana", Apellido: "Díaz", Edad: 25, Correo: "ana@example.com", Telefono: "+573001234567", Direccion: ""}, "la dirección no puede estar vacía"},
 	}
 
 	for _, tt := range casos {
 		t.Run(tt.nombre, func(t *testing.T) {
-			err := services.CrearPersona(tt.persona)
+			_, err := services.CrearPersona(context.Background(), tt.persona)
+			assert.ErrorIs(t, err, services.ErrValidation)
 			assert.EqualError(t, err, tt.errorEsperado)
 		})
 	}
 }
+
+// TestCrearPersonaErrorBaseDeDatos prueba que una falla al insertar se reporte como error de infraestructura
+func TestCrearPersonaErrorBaseDeDatos(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	persona := models.Persona{
+		TipoDocumento:  models.CC,
+		Documento:      "123",
+		Nombre:         "Ana",
+		Apellido:       "Díaz",
+		Edad:           25,
+		Correo:         "ana@example.com",
+		Telefono:       "+573001234567",
+		Direccion:      "Calle Falsa 123",
+		Version:        models.VersionInicial,
+		CreadoEn:       instante,
+		CreadoPor:      services.UsuarioAnonimo,
+		ActualizadoEn:  instante,
+		ActualizadoPor: services.UsuarioAnonimo,
+	}
+
+	mockRepo.On("InsertarPersona", mock.Anything, persona).Return(primitive.NilObjectID, errors.New("servidor no disponible"))
+
+	_, err := services.CrearPersona(context.Background(), persona)
+
+	assert.ErrorIs(t, err, services.ErrInfrastructure)
+	assert.EqualError(t, err, "servidor no disponible")
+	mockRepo.AssertExpectations(t)
+}
+
+// TestCrearPersonaReportaTodosLosErrores prueba que la validación no se detenga en el primer campo inválido
+func TestCrearPersonaReportaTodosLosErrores(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	_, err := services.CrearPersona(context.Background(), models.Persona{Documento: "123", Nombre: "Ana", Edad: -3, Correo: "ana"})
+
+	var errores services.ValidationErrors
+	assert.ErrorAs(t, err, &errores)
+	assert.ErrorIs(t, err, services.ErrValidation)
+	assert.Equal(t, services.ValidationErrors{
+		{Campo: "apellido", Regla: services.ReglaRequerido, Mensaje: "el apellido no puede estar vacío"},
+		{Campo: "edad", Regla: services.ReglaRango, Mensaje: "la edad debe ser un número entero mayor a 0"},
+		{Campo: "correo", Regla: services.ReglaFormato, Mensaje: "el correo es inválido"},
+		{Campo: "telefono", Regla: services.ReglaRequerido, Mensaje: "el teléfono no puede estar vacío"},
+		{Campo: "direccion", Regla: services.ReglaRequerido, Mensaje: "la dirección no puede estar vacía"},
+	}, errores)
+	mockRepo.AssertNotCalled(t, "InsertarPersona", mock.Anything, mock.Anything)
+}
+
+// TestCrearPersonaNormalizaDatos prueba que la persona se guarde con los datos normalizados
+func TestCrearPersonaNormalizaDatos(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	entrada := models.Persona{
+		TipoDocumento: models.CC,
+		Documento:     " 123 ",
+		Nombre:        " Ana",
+		Apellido:      "Díaz ",
+		Edad:          25,
+		Correo:        "ANA@Example.com",
+		Telefono:      "300-123-4567",
+		Direccion:     "Calle Falsa 123 ",
+	}
+	guardada := models.Persona{
+		TipoDocumento:  models.CC,
+		Documento:      "123",
+		Nombre:         "Ana",
+		Apellido:       "Díaz",
+		Edad:           25,
+		Correo:         "ana@example.com",
+		Telefono:       "+573001234567",
+		Direccion:      "Calle Falsa 123",
+		Version:        models.VersionInicial,
+		CreadoEn:       instante,
+		CreadoPor:      services.UsuarioAnonimo,
+		ActualizadoEn:  instante,
+		ActualizadoPor: services.UsuarioAnonimo,
+	}
+
+	mockRepo.On("InsertarPersona", mock.Anything, guardada).Return(primitive.NewObjectID(), nil)
+
+	_, err := services.CrearPersona(context.Background(), entrada)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestCrearPersonaMismoNumeroOtroTipo prueba que el mismo número con otro tipo de documento sea otra persona
+func TestCrearPersonaMismoNumeroOtroTipo(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	persona := models.Persona{
+		TipoDocumento:  models.TI,
+		Documento:      "1020304050",
+		Nombre:         "Ana",
+		Apellido:       "Díaz",
+		Edad:           15,
+		Correo:         "ana@example.com",
+		Telefono:       "+573001234567",
+		Direccion:      "Calle Falsa 123",
+		Version:        models.VersionInicial,
+		CreadoEn:       instante,
+		CreadoPor:      services.UsuarioAnonimo,
+		ActualizadoEn:  instante,
+		ActualizadoPor: services.UsuarioAnonimo,
+	}
+
+	mockRepo.On("InsertarPersona", mock.Anything, persona).Return(primitive.NewObjectID(), nil)
+
+	_, err := services.CrearPersona(context.Background(), persona)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestCrearPersonaTipoDocumentoInvalido prueba el rechazo de tipos desconocidos y números con otro formato
+func TestCrearPersonaTipoDocumentoInvalido(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	base := models.Persona{
+		Documento: "123",
+		Nombre:    "Ana",
+		Apellido:  "Díaz",
+		Edad:      25,
+		Correo:    "ana@example.com",
+		Telefono:  "+573001234567",
+		Direccion: "Calle Falsa 123",
+	}
+
+	desconocido := base
+	desconocido.TipoDocumento = "RC"
+	_, err := services.CrearPersona(context.Background(), desconocido)
+	assert.EqualError(t, err, "el tipo de documento debe ser CC, TI, CE, NIT o PA")
+
+	tarjeta := base
+	tarjeta.TipoDocumento = models.TI
+	_, err = services.CrearPersona(context.Background(), tarjeta)
+	assert.EqualError(t, err, "el documento no tiene un formato válido para el tipo TI")
+
+	mockRepo.AssertNotCalled(t, "InsertarPersona", mock.Anything, mock.Anything)
+}
+
+// TestCrearPersonaAuditoria prueba que la auditoría la asigne el servicio con el usuario de la petición
+func TestCrearPersonaAuditoria(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	entrada := models.Persona{
+		TipoDocumento:  models.CC,
+		Documento:      "123",
+		Nombre:         "Ana",
+		Apellido:       "Díaz",
+		Edad:           25,
+		Correo:         "ana@example.com",
+		Telefono:       "+573001234567",
+		Direccion:      "Calle Falsa 123",
+		Version:        9,
+		CreadoEn:       instante.AddDate(-1, 0, 0),
+		CreadoPor:      "impostor",
+		ActualizadoPor: "impostor",
+	}
+
+	mockRepo.On("InsertarPersona", mock.Anything, mock.MatchedBy(func(p models.Persona) bool {
+		return p.Version == models.VersionInicial &&
+			p.CreadoEn.Equal(instante) && p.ActualizadoEn.Equal(instante) &&
+			p.CreadoPor == "operador" && p.ActualizadoPor == "operador"
+	})).Return(primitive.NewObjectID(), nil)
+
+	ctx := contexto.ConUsuario(context.Background(), "operador")
+	_, err := services.CrearPersona(ctx, entrada)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,133 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func personaDeLote(documento string) models.Persona {
+	return models.Persona{
+		Documento: documento,
+		Nombre:    "Ana",
+		Apellido:  "Díaz",
+		Edad:      25,
+		Correo:    "ana@example.com",
+		Telefono:  "3001234567",
+		Direccion: "Calle Falsa 123",
+	}
+}
+
+// conLongitud compara solo la cantidad de personas que llegan al repositorio
+func conLongitud(n int) any {
+	return mock.MatchedBy(func(personas []models.Persona) bool { return len(personas) == n })
+}
+
+func TestCrearPersonas(t *testing.T) {
+	invalida := personaDeLote("456")
+	invalida.Correo = "ana"
+	lote := []models.Persona{personaDeLote("123"), invalida, personaDeLote("789")}
+
+	t.Run("Debe crear las válidas y reportar cada falla", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("InsertarPersonas", mock.Anything, conLongitud(2), false).Return(mongo.BulkWriteException{
+			WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 1, Code: 11000, Message: "E11000 duplicate key error"}}},
+		})
+
+		resultados, err := services.CrearPersonas(context.Background(), lote, false)
+
+		assert.NoError(t, err)
+		assert.Len(t, resultados, 3)
+		assert.NoError(t, resultados[0].Err)
+		assert.False(t, resultados[0].ID.IsZero())
+		assert.ErrorIs(t, resultados[1].Err, services.ErrValidation)
+		assert.ErrorIs(t, resultados[2].Err, services.ErrDuplicate)
+		assert.True(t, resultados[2].ID.IsZero())
+	})
+
+	t.Run("Debe registrar las creadas en el historial con una sola escritura", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("InsertarPersonas", mock.Anything, conLongitud(2), false).Return(mongo.BulkWriteException{
+			WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 1, Code: 11000, Message: "E11000 duplicate key error"}}},
+		})
+		mockHistorial := nuevoHistorial(t)
+		mockHistorial.On("RegistrarCambios", mock.Anything, mock.MatchedBy(func(c []models.CambioPersona) bool {
+			return len(c) == 1 && c[0].Operacion == models.OperacionCrear && c[0].Documento == "123" &&
+				c[0].Cambios["nombre"] == models.CambioCampo{Despues: "Ana"}
+		})).Return(nil).Once()
+
+		_, err := services.CrearPersonas(context.Background(), lote, false)
+
+		assert.NoError(t, err)
+		mockHistorial.AssertNotCalled(t, "RegistrarCambio", mock.Anything, mock.Anything)
+		mockHistorial.AssertExpectations(t)
+	})
+
+	t.Run("Debe asignar la versión y la auditoría a cada persona", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("InsertarPersonas", mock.Anything, mock.MatchedBy(func(personas []models.Persona) bool {
+			p := personas[0]
+			return p.Version == models.VersionInicial && p.CreadoEn.Equal(instante) && p.Telefono == "+573001234567" && !p.ID.IsZero()
+		}), false).Return(nil)
+
+		resultados, err := services.CrearPersonas(context.Background(), lote[:1], false)
+
+		assert.NoError(t, err)
+		assert.NoError(t, resultados[0].Err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Con atomicidad no debe guardar nada si alguna es inválida", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+
+		resultados, err := services.CrearPersonas(context.Background(), lote, true)
+
+		assert.NoError(t, err)
+		assert.ErrorIs(t, resultados[0].Err, services.ErrLoteNoAplicado)
+		assert.ErrorIs(t, resultados[1].Err, services.ErrValidation)
+		assert.ErrorIs(t, resultados[2].Err, services.ErrLoteNoAplicado)
+		mockRepo.AssertNotCalled(t, "InsertarPersonas", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Con atomicidad un duplicado revierte el lote", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("InsertarPersonas", mock.Anything, conLongitud(2), true).Return(mongo.BulkWriteException{
+			WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 1, Code: 11000, Message: "E11000 duplicate key error"}}},
+		})
+
+		resultados, err := services.CrearPersonas(context.Background(), []models.Persona{personaDeLote("123"), personaDeLote("123")}, true)
+
+		assert.NoError(t, err)
+		assert.ErrorIs(t, resultados[0].Err, services.ErrLoteNoAplicado)
+		assert.True(t, resultados[0].ID.IsZero())
+		assert.ErrorIs(t, resultados[1].Err, services.ErrDuplicate)
+	})
+
+	t.Run("Debe fallar si no se sabe qué se guardó", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("InsertarPersonas", mock.Anything, conLongitud(1), false).Return(errors.New("servidor no disponible"))
+
+		_, err := services.CrearPersonas(context.Background(), lote[:1], false)
+
+		assert.ErrorIs(t, err, services.ErrInfrastructure)
+	})
+
+	t.Run("Debe rechazar un lote demasiado grande", func(t *testing.T) {
+		_, err := services.CrearPersonas(context.Background(), make([]models.Persona, services.MaximoLote+1), false)
+
+		assert.ErrorIs(t, err, services.ErrLoteDemasiadoGrande)
+	})
+}
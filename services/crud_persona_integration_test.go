@@ -5,6 +5,8 @@ package services_test
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 
@@ -48,54 +50,312 @@ func TestCrearBuscarActualizarEliminarPersona(t *testing.T) {
 	assert.NoError(t, err)
 
 	repositories.SetCollection(config.Collection)
+	assert.NoError(t, repositories.CrearIndices(context.Background()))
 
 	// Asegurarse de que la colección esté vacía antes de cada prueba
 	_, err = config.Collection.DeleteMany(context.Background(), bson.M{})
 	assert.NoError(t, err)
 
-
 	// 4. Inyectar el repositorio real al servicio
 	services.SetPersonaRepository(repositories.RealPersonaRepository{})
 
+	// El historial usa su propia colección
+	repositories.SetColeccionHistorial(config.ColeccionHistorial)
+	assert.NoError(t, repositories.CrearIndicesHistorial(context.Background()))
+	_, err = config.ColeccionHistorial.DeleteMany(context.Background(), bson.M{})
+	assert.NoError(t, err)
+	services.SetHistorialRepository(repositories.RealHistorialRepository{})
+	defer services.SetHistorialRepository(nil)
+
+	// Un reloj que avanza en cada lectura deja cada cambio en un instante distinto
+	hora := instante
+	services.SetReloj(func() time.Time {
+		hora = hora.Add(time.Second)
+		return hora
+	})
+	defer services.SetReloj(func() time.Time { return instante })
+
 	// 5. Crear persona de prueba
 	persona := models.Persona{
-		Documento: "12345",
-		Nombre:    "Persona",
-		Apellido:  "Prueba",
-		Edad:      28,
-		Correo:    "persona@prueba.com",
-		Telefono:  "3001234567",
-		Direccion: "Calle Falsa 123",
+		TipoDocumento: models.CC,
+		Documento:     "12345",
+		Nombre:        "Persona",
+		Apellido:      "Prueba",
+		Edad:          28,
+		Correo:        "persona@prueba.com",
+		Telefono:      "3001234567",
+		Direccion:     "Calle Falsa 123",
 	}
 
 	// Crear
-	err = services.CrearPersona(persona)
+	nueva, err := services.CrearPersona(context.Background(), persona)
+	assert.NoError(t, err)
+	assert.False(t, nueva.ID.IsZero())
+
+	// Un segundo registro con la misma identidad lo rechaza el índice único
+	_, err = services.CrearPersona(context.Background(), persona)
+	assert.ErrorIs(t, err, services.ErrDuplicate)
+
+	// Listar
+	pagina, err := services.ListarPersonas(context.Background(), models.ConsultaPersonas{Limite: 10, Orden: "apellido"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pagina.Total)
+	assert.Len(t, pagina.Datos, 1)
+	assert.Empty(t, pagina.SiguienteCursor)
+
+	filtradas, err := services.ListarPersonas(context.Background(), models.ConsultaPersonas{Filtro: models.FiltroPersonas{DominioCorreo: "otro.com"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), filtradas.Total)
+
+	// Búsqueda de texto sin distinguir mayúsculas
+	resultados, err := services.BuscarPersonas(context.Background(), "PRUEBA", 10)
 	assert.NoError(t, err)
+	assert.Len(t, resultados, 1)
 
 	// Buscar
-	encontrada, err := services.BuscarPersonaPorDocumento(persona.Documento)
+	encontrada, err := services.BuscarPersonaPorDocumento(context.Background(), models.CC, persona.Documento)
 	assert.NoError(t, err)
 	assert.Equal(t, "Persona", encontrada.Nombre)
+	assert.Equal(t, models.VersionInicial, encontrada.Version)
+	assert.Equal(t, services.UsuarioAnonimo, encontrada.CreadoPor)
+	assert.False(t, encontrada.CreadoEn.IsZero())
+	assert.Equal(t, nueva.ID, encontrada.ID)
 
 	// Actualizar
 	persona.Nombre = "Persona Actualizada"
 	persona.Correo = "nuevo@correo.com"
-	err = services.ModificarPersona(persona.Documento, persona)
+	modificada, err := services.ModificarPersona(context.Background(), models.CC, persona.Documento, services.VersionEsperada{ID: encontrada.ID, Numero: encontrada.Version}, persona)
 	assert.NoError(t, err)
+	assert.Equal(t, encontrada.ID, modificada.ID)
+	assert.Equal(t, encontrada.Version+1, modificada.Version)
 
-	actualizada, err := services.BuscarPersonaPorDocumento(persona.Documento)
+	actualizada, err := services.BuscarPersonaPorDocumento(context.Background(), models.CC, persona.Documento)
 	assert.NoError(t, err)
 	assert.Equal(t, "Persona Actualizada", actualizada.Nombre)
 	assert.Equal(t, "nuevo@correo.com", actualizada.Correo)
+	assert.Equal(t, encontrada.Version+1, actualizada.Version)
+
+	// Una escritura con la versión vieja ya no se aplica
+	_, err = services.ModificarPersona(context.Background(), models.CC, persona.Documento, services.VersionEsperada{ID: encontrada.ID, Numero: encontrada.Version}, persona)
+	assert.ErrorIs(t, err, services.ErrPreconditionFailed)
+
+	// Actualizar parcialmente
+	_, err = services.ParchearPersona(context.Background(), models.CC, persona.Documento, services.VersionEsperada{ID: actualizada.ID, Numero: actualizada.Version}, map[string]any{"telefono": "3109998877"})
+	assert.NoError(t, err)
+
+	parcheada, err := services.BuscarPersonaPorDocumento(context.Background(), models.CC, persona.Documento)
+	assert.NoError(t, err)
+	assert.Equal(t, "+573109998877", parcheada.Telefono)
+	assert.Equal(t, "Persona Actualizada", parcheada.Nombre)
 
 	// Eliminar
-	err = services.BorrarPersona(persona.Documento)
+	err = services.BorrarPersona(context.Background(), models.CC, persona.Documento, services.VersionEsperada{ID: parcheada.ID, Numero: parcheada.Version})
 	assert.NoError(t, err)
 
 	// Confirmar eliminación
-	_, err = services.BuscarPersonaPorDocumento(persona.Documento)
+	_, err = services.BuscarPersonaPorDocumento(context.Background(), models.CC, persona.Documento)
 	assert.Error(t, err)
 	assert.Equal(t, "persona no encontrada", err.Error())
 
+	// El listado solo la incluye si se pide
+	conEliminadas, err := services.ListarPersonas(context.Background(), models.ConsultaPersonas{Filtro: models.FiltroPersonas{IncluirEliminados: true}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), conEliminadas.Total)
+	assert.False(t, conEliminadas.Datos[0].EliminadoEn.IsZero())
+
+	// Restaurar
+	err = services.RestaurarPersona(context.Background(), models.CC, persona.Documento, services.VersionCualquiera)
+	assert.NoError(t, err)
+
+	restaurada, err := services.BuscarPersonaPorDocumento(context.Background(), models.CC, persona.Documento)
+	assert.NoError(t, err)
+	assert.True(t, restaurada.EliminadoEn.IsZero())
+
+	// Una persona activa no se puede purgar
+	err = services.PurgarPersona(context.Background(), models.CC, persona.Documento)
+	assert.ErrorIs(t, err, services.ErrNotFound)
+
+	// Eliminar y purgar definitivamente
+	assert.NoError(t, services.BorrarPersona(context.Background(), models.CC, persona.Documento, services.VersionEsperada{ID: restaurada.ID, Numero: restaurada.Version}))
+	assert.NoError(t, services.PurgarPersona(context.Background(), models.CC, persona.Documento))
+
+	err = services.RestaurarPersona(context.Background(), models.CC, persona.Documento, services.VersionCualquiera)
+	assert.ErrorIs(t, err, services.ErrNotFound)
+
+	// El historial conserva cada operación, incluso después de la purga
+	cambios, err := services.HistorialPersona(context.Background(), models.CC, persona.Documento)
+	assert.NoError(t, err)
+	assert.Len(t, cambios, 7)
+	assert.Equal(t, models.OperacionCrear, cambios[0].Operacion)
+	assert.Equal(t, models.OperacionPurgar, cambios[6].Operacion)
+
+	// Se puede reconstruir la persona en cualquier momento de su historia
+	original, err := services.PersonaEnFecha(context.Background(), models.CC, persona.Documento, cambios[0].Fecha)
+	assert.NoError(t, err)
+	assert.Equal(t, "Persona", original.Nombre)
+	assert.Equal(t, models.VersionInicial, original.Version)
+
+	antesDelParche, err := services.PersonaEnFecha(context.Background(), models.CC, persona.Documento, cambios[1].Fecha)
+	assert.NoError(t, err)
+	assert.Equal(t, "Persona Actualizada", antesDelParche.Nombre)
+	assert.Equal(t, "+573001234567", antesDelParche.Telefono)
+
+	_, err = services.PersonaEnFecha(context.Background(), models.CC, persona.Documento, cambios[0].Fecha.Add(-time.Second))
+	assert.ErrorIs(t, err, services.ErrNotFound)
+
+	// Creación masiva: la persona repetida no impide crear las demás
+	lote := []models.Persona{persona, persona}
+	lote[1].Documento = "67890"
+	creaciones, err := services.CrearPersonas(context.Background(), append(lote, persona), false)
+	assert.NoError(t, err)
+	assert.NoError(t, creaciones[0].Err)
+	assert.NoError(t, creaciones[1].Err)
+	assert.ErrorIs(t, creaciones[2].Err, services.ErrDuplicate)
+
+	creada, err := services.BuscarPersonaPorDocumento(context.Background(), models.CC, "67890")
+	assert.NoError(t, err)
+	assert.Equal(t, creaciones[1].ID, creada.ID)
+
+	// Operaciones masivas por filtro
+	simulacion, err := services.ParchearPersonas(context.Background(), models.FiltroPersonas{Direccion: "falsa"}, models.ActualizacionMasiva{
+		Reemplazos: map[string]models.Reemplazo{"direccion": {Buscar: "Falsa", Reemplazo: "Verdadera"}},
+	}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), simulacion.Modificadas)
+	assert.Len(t, simulacion.Muestra, 2)
+
+	masiva, err := services.ParchearPersonas(context.Background(), models.FiltroPersonas{Direccion: "falsa"}, models.ActualizacionMasiva{
+		Reemplazos: map[string]models.Reemplazo{"direccion": {Buscar: "Falsa", Reemplazo: "Verdadera"}},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), masiva.Modificadas)
+
+	// Un texto que empieza con $ se reemplaza tal cual y no se lee como un campo
+	conDolarMasivo, err := services.ParchearPersonas(context.Background(), models.FiltroPersonas{Direccion: "verdadera"}, models.ActualizacionMasiva{
+		Reemplazos: map[string]models.Reemplazo{"direccion": {Buscar: "123", Reemplazo: "$nombre"}},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), conDolarMasivo.Modificadas)
+
+	conDolar, err := services.BuscarPersonaPorDocumento(context.Background(), models.CC, "67890")
+	assert.NoError(t, err)
+	assert.Equal(t, "Calle Verdadera $nombre", conDolar.Direccion)
+
+	conDolarMasivo, err = services.ParchearPersonas(context.Background(), models.FiltroPersonas{Direccion: "verdadera"}, models.ActualizacionMasiva{
+		Reemplazos: map[string]models.Reemplazo{"direccion": {Buscar: "$nombre", Reemplazo: "123"}},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), conDolarMasivo.Modificadas)
+
+	corregida, err := services.BuscarPersonaPorDocumento(context.Background(), models.CC, "67890")
+	assert.NoError(t, err)
+	assert.Equal(t, "Calle Verdadera 123", corregida.Direccion)
+	assert.Equal(t, creada.Version+3, corregida.Version)
+
+	// La exportación recorre el cursor en orden de creación
+	var exportadas []string
+	err = services.ExportarPersonas(context.Background(), models.FiltroPersonas{Direccion: "verdadera"}, func(p models.Persona) error {
+		exportadas = append(exportadas, p.Documento)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{persona.Documento, "67890"}, exportadas)
+
+	eliminadas, err := services.BorrarPersonas(context.Background(), models.FiltroPersonas{DominioCorreo: "correo.com"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), eliminadas.Modificadas)
+
+	cambios, err = services.HistorialPersona(context.Background(), models.CC, "67890")
+	assert.NoError(t, err)
+	assert.Equal(t, models.OperacionEliminar, cambios[len(cambios)-1].Operacion)
+	assert.Equal(t, corregida.Version+1, cambios[len(cambios)-1].Version)
+
+	purgadas, err := services.PurgarPersonas(context.Background(), models.FiltroPersonas{DominioCorreo: "correo.com"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purgadas.Modificadas)
+
+	// Los teléfonos guardados antes de normalizarlos se migran a E.164
+	_, err = config.Collection.InsertMany(context.Background(), []any{
+		bson.M{"tipo_documento": models.CC, "documento": "111", "nombre": "Antigua", "telefono": "300 123 4567", "version": models.VersionInicial},
+		bson.M{"tipo_documento": models.CC, "documento": "222", "nombre": "Ilegible", "telefono": "sin teléfono", "version": models.VersionInicial},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, repositories.MigrarTelefonos(context.Background(), services.NormalizarTelefono))
+
+	migradas, err := services.ListarPersonas(context.Background(), models.ConsultaPersonas{Filtro: models.FiltroPersonas{PrefijoTelefono: "300"}})
+	assert.NoError(t, err)
+	if assert.Len(t, migradas.Datos, 1) {
+		assert.Equal(t, "+573001234567", migradas.Datos[0].Telefono)
+		assert.Equal(t, models.VersionInicial+1, migradas.Datos[0].Version)
+	}
+
 	defer config.CerrarMongo()
 }
+
+func TestCrearPersonaConcurrente(t *testing.T) {
+	ctx := context.Background()
+
+	req := testcontainers.ContainerRequest{
+		Image:        "mongo:6.0",
+		ExposedPorts: []string{"27017/tcp"},
+		WaitingFor:   wait.ForListeningPort("27017/tcp").WithStartupTimeout(20 * time.Second),
+	}
+	mongoC, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	assert.NoError(t, err)
+	defer mongoC.Terminate(ctx)
+
+	endpoint, err := mongoC.Endpoint(ctx, "")
+	assert.NoError(t, err)
+
+	t.Setenv("MONGO_URI", "mongodb://"+endpoint)
+	t.Setenv("MONGO_DB", "testdb")
+	t.Setenv("COLLECTION_NAME", "personas_concurrencia")
+
+	err = config.ConectarMongo()
+	assert.NoError(t, err)
+	defer config.CerrarMongo()
+
+	repositories.SetCollection(config.Collection)
+	assert.NoError(t, repositories.CrearIndices(context.Background()))
+	services.SetPersonaRepository(repositories.RealPersonaRepository{})
+
+	persona := models.Persona{
+		Documento: "54321",
+		Nombre:    "Persona",
+		Apellido:  "Concurrente",
+		Edad:      40,
+		Correo:    "concurrente@prueba.com",
+		Telefono:  "3001234567",
+		Direccion: "Calle 1",
+	}
+
+	// Varias creaciones simultáneas del mismo documento: solo una debe tener éxito
+	const intentos = 10
+	errores := make(chan error, intentos)
+	var wg sync.WaitGroup
+	for i := 0; i < intentos; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := services.CrearPersona(context.Background(), persona)
+			errores <- err
+		}()
+	}
+	wg.Wait()
+	close(errores)
+
+	exitos, duplicados := 0, 0
+	for err := range errores {
+		switch {
+		case err == nil:
+			exitos++
+		case errors.Is(err, services.ErrDuplicate):
+			duplicados++
+		}
+	}
+	assert.Equal(t, 1, exitos)
+	assert.Equal(t, intentos-1, duplicados)
+}
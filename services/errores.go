@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"strings"
+)
+
+// Errores de dominio que devuelve el servicio. Los controladores los traducen
+// a códigos HTTP, por lo que se deben comparar con errors.Is y no por su mensaje
+var (
+	ErrNotFound       = errors.New("persona no encontrada")
+	ErrDuplicate      = errors.New("ya existe una persona con ese documento")
+	ErrValidation     = errors.New("los datos de la persona son inválidos")
+	ErrInvalidQuery   = errors.New("los parámetros de la consulta son inválidos")
+	ErrImmutableField = errors.New("no se puede modificar el documento de una persona")
+	ErrInfrastructure = errors.New("error de infraestructura")
+
+	// ErrPreconditionFailed indica que la persona cambió desde que el cliente la leyó
+	ErrPreconditionFailed = errors.New("la persona fue modificada por otra operación")
+	// ErrPreconditionRequired indica que una escritura no trae la versión que espera modificar
+	ErrPreconditionRequired = errors.New("se debe indicar la versión de la persona con la cabecera If-Match")
+
+	// ErrLoteDemasiadoGrande indica que una creación masiva trae más personas de las permitidas
+	ErrLoteDemasiadoGrande = errors.New("el lote supera la cantidad máxima de personas")
+	// ErrLoteNoAplicado indica que la persona era válida pero no se guardó porque
+	// otra persona del mismo lote atómico falló
+	ErrLoteNoAplicado = errors.New("la persona no se guardó porque otra persona del lote falló")
+)
+
+// Reglas de validación que se informan junto a cada campo rechazado
+const (
+	ReglaRequerido = "requerido"
+	ReglaRango     = "rango"
+	ReglaFormato   = "formato"
+)
+
+// ValidationError indica que un campo de la persona no cumple una regla
+type ValidationError struct {
+	Campo   string
+	Regla   string
+	Mensaje string
+}
+
+func (e ValidationError) Error() string { return e.Mensaje }
+
+func (e ValidationError) Is(target error) bool { return target == ErrValidation }
+
+// ValidationErrors agrupa todos los campos rechazados en una misma validación
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	mensajes := make([]string, len(e))
+	for i, v := range e {
+		mensajes[i] = v.Mensaje
+	}
+	return strings.Join(mensajes, "; ")
+}
+
+func (e ValidationErrors) Is(target error) bool { return target == ErrValidation }
+
+// comoError devuelve nil cuando no hay fallas, para no retornar un slice vacío como error
+func (e ValidationErrors) comoError() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// QueryError indica que un parámetro de consulta es inválido
+type QueryError struct {
+	Parametro string
+	Mensaje   string
+}
+
+func (e QueryError) Error() string { return e.Mensaje }
+
+func (e QueryError) Is(target error) bool { return target == ErrInvalidQuery }
+
+// infraError envuelve una falla de la base de datos conservando su mensaje original
+type infraError struct {
+	causa error
+}
+
+func (e infraError) Error() string { return e.causa.Error() }
+
+func (e infraError) Unwrap() []error { return []error{ErrInfrastructure, e.causa} }
+
+// errorInfraestructura marca como ErrInfrastructure cualquier error del repositorio
+func errorInfraestructura(err error) error {
+	if err == nil {
+		return nil
+	}
+	return infraError{causa: err}
+}
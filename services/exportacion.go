@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+)
+
+// ExportarPersonas entrega a visitar, una a una, todas las personas que cumplen el
+// filtro. Un error de visitar corta el recorrido y se devuelve sin envolver, para
+// distinguirlo de una falla de la base de datos
+func ExportarPersonas(ctx context.Context, filtro models.FiltroPersonas, visitar func(models.Persona) error) error {
+	if err := validarFiltro(&filtro); err != nil {
+		return err
+	}
+
+	var errVisita error
+	err := Repo.RecorrerPersonas(ctx, filtro, func(p models.Persona) error {
+		errVisita = visitar(p)
+		return errVisita
+	})
+	if err != nil && err == errVisita {
+		return err
+	}
+	return errorInfraestructura(err)
+}
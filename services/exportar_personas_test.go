@@ -0,0 +1,65 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestExportarPersonas(t *testing.T) {
+	personas := personasDeDireccion("Calle 1", "Calle 2", "Calle 3")
+
+	t.Run("Debe entregar cada persona con el filtro normalizado", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("RecorrerPersonas", mock.Anything, models.FiltroPersonas{DominioCorreo: "example.com"}).Return(personas, nil)
+
+		var documentos []string
+		err := services.ExportarPersonas(context.Background(), models.FiltroPersonas{DominioCorreo: " @example.com"}, func(p models.Persona) error {
+			documentos = append(documentos, p.Documento)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2", "3"}, documentos)
+	})
+
+	t.Run("Debe cortar el recorrido con el error de la visita", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("RecorrerPersonas", mock.Anything, mock.Anything).Return(personas, nil)
+		errEscritura := errors.New("conexión cerrada")
+
+		visitas := 0
+		err := services.ExportarPersonas(context.Background(), models.FiltroPersonas{}, func(models.Persona) error {
+			visitas++
+			return errEscritura
+		})
+
+		assert.Equal(t, errEscritura, err)
+		assert.Equal(t, 1, visitas)
+	})
+
+	t.Run("Debe marcar las fallas del cursor como de infraestructura", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("RecorrerPersonas", mock.Anything, mock.Anything).Return([]models.Persona{}, errors.New("cursor perdido"))
+
+		err := services.ExportarPersonas(context.Background(), models.FiltroPersonas{}, func(models.Persona) error { return nil })
+
+		assert.ErrorIs(t, err, services.ErrInfrastructure)
+	})
+
+	t.Run("Debe validar el filtro", func(t *testing.T) {
+		edad := -1
+		err := services.ExportarPersonas(context.Background(), models.FiltroPersonas{EdadMin: &edad}, func(models.Persona) error { return nil })
+
+		assert.ErrorIs(t, err, services.ErrInvalidQuery)
+	})
+}
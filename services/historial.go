@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/contexto"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/repositories"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Historial guarda los cambios de cada persona. Si no se inyecta, las escrituras
+// no dejan registro
+var Historial repositories.HistorialRepository
+
+func SetHistorialRepository(h repositories.HistorialRepository) {
+	Historial = h
+}
+
+// registrarCambio guarda en el historial el valor anterior y el nuevo de cada campo
+// modificado. anterior es la persona antes de la operación, nil si no existía.
+// La escritura ya se aplicó, así que un fallo aquí se registra en el log en lugar
+// de devolverse al cliente
+func registrarCambio(ctx context.Context, operacion models.Operacion, tipo models.TipoDocumento, documento string, anterior *models.Persona, cambios map[string]any) {
+	if Historial == nil {
+		return
+	}
+	ctx = contextoHistorial(ctx)
+
+	cambio, err := nuevoCambio(ctx, operacion, tipo, documento, anterior, cambios)
+	if err != nil {
+		log.Printf("⚠️ no se pudo registrar el cambio %s de %s %s [%s]: %v", operacion, tipo, documento, contexto.RequestID(ctx), err)
+		return
+	}
+
+	if err := Historial.RegistrarCambio(ctx, cambio); err != nil {
+		log.Printf("⚠️ no se pudo registrar el cambio %s de %s %s [%s]: %v", operacion, tipo, documento, cambio.RequestID, err)
+	}
+}
+
+// registrarCambios guarda de una vez el historial de las personas escritas por una
+// operación masiva. Igual que registrarCambio, los fallos solo quedan en el log
+func registrarCambios(ctx context.Context, operacion models.Operacion, modificaciones []modificacion) {
+	if Historial == nil || len(modificaciones) == 0 {
+		return
+	}
+	ctx = contextoHistorial(ctx)
+
+	cambios := make([]models.CambioPersona, 0, len(modificaciones))
+	for _, m := range modificaciones {
+		cambio, err := nuevoCambio(ctx, operacion, m.persona.TipoDocumento, m.persona.Documento, &m.persona, m.cambios)
+		if err != nil {
+			log.Printf("⚠️ no se pudo registrar el cambio %s de %s %s [%s]: %v", operacion, m.persona.TipoDocumento, m.persona.Documento, contexto.RequestID(ctx), err)
+			continue
+		}
+		cambios = append(cambios, cambio)
+	}
+
+	guardarCambios(ctx, operacion, cambios)
+}
+
+// registrarCreaciones deja en el historial, de una vez, todos los campos de las personas
+// creadas en un lote
+func registrarCreaciones(ctx context.Context, personas []models.Persona) {
+	if Historial == nil || len(personas) == 0 {
+		return
+	}
+	ctx = contextoHistorial(ctx)
+
+	cambios := make([]models.CambioPersona, 0, len(personas))
+	for _, p := range personas {
+		campos, err := comoDocumento(p)
+		if err != nil {
+			log.Printf("⚠️ no se pudo registrar el cambio %s de %s %s [%s]: %v", models.OperacionCrear, p.TipoDocumento, p.Documento, contexto.RequestID(ctx), err)
+			continue
+		}
+		cambio, err := nuevoCambio(ctx, models.OperacionCrear, p.TipoDocumento, p.Documento, nil, campos)
+		if err != nil {
+			log.Printf("⚠️ no se pudo registrar el cambio %s de %s %s [%s]: %v", models.OperacionCrear, p.TipoDocumento, p.Documento, contexto.RequestID(ctx), err)
+			continue
+		}
+		cambios = append(cambios, cambio)
+	}
+
+	guardarCambios(ctx, models.OperacionCrear, cambios)
+}
+
+// guardarCambios escribe con un solo InsertMany las entradas de una operación por lotes
+func guardarCambios(ctx context.Context, operacion models.Operacion, cambios []models.CambioPersona) {
+	if len(cambios) == 0 {
+		return
+	}
+	if err := Historial.RegistrarCambios(ctx, cambios); err != nil {
+		log.Printf("⚠️ no se pudo registrar el historial de la operación masiva %s [%s]: %v", operacion, contexto.RequestID(ctx), err)
+	}
+}
+
+// nuevoCambio arma la entrada del historial con el valor anterior y el nuevo de cada campo
+func nuevoCambio(ctx context.Context, operacion models.Operacion, tipo models.TipoDocumento, documento string, anterior *models.Persona, cambios map[string]any) (models.CambioPersona, error) {
+	var previo bson.M
+	if anterior != nil {
+		var err error
+		if previo, err = comoDocumento(*anterior); err != nil {
+			return models.CambioPersona{}, err
+		}
+	}
+
+	cambio := models.CambioPersona{
+		TipoDocumento: tipo,
+		Documento:     documento,
+		Operacion:     operacion,
+		Cambios:       make(map[string]models.CambioCampo, len(cambios)),
+		Actor:         actor(ctx),
+		Fecha:         ahora(),
+		RequestID:     contexto.RequestID(ctx),
+	}
+	if version, ok := cambios["version"].(int64); ok {
+		cambio.Version = version
+	}
+	for campo, valor := range cambios {
+		if campo == "_id" {
+			continue
+		}
+		cambio.Cambios[campo] = models.CambioCampo{Antes: previo[campo], Despues: valor}
+	}
+	return cambio, nil
+}
+
+// contextoHistorial separa la escritura del historial de la cancelación de la petición:
+// la persona ya se guardó, y si el cliente se desconecta o vence el plazo del gateway
+// el registro se perdería. Conserva los valores de la petición (usuario y request ID),
+// y el repositorio le aplica su propio tiempo máximo
+func contextoHistorial(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+// registrarCreacion deja en el historial todos los campos de una persona nueva
+func registrarCreacion(ctx context.Context, p models.Persona) {
+	campos, err := comoDocumento(p)
+	if err != nil {
+		log.Printf("⚠️ no se pudo registrar el cambio %s de %s %s [%s]: %v", models.OperacionCrear, p.TipoDocumento, p.Documento, contexto.RequestID(ctx), err)
+		return
+	}
+	registrarCambio(ctx, models.OperacionCrear, p.TipoDocumento, p.Documento, nil, campos)
+}
+
+// camposPurgados arma los cambios de una purga: todos los campos pasan a no existir
+func camposPurgados(p models.Persona) (map[string]any, error) {
+	campos, err := comoDocumento(p)
+	if err != nil {
+		return nil, err
+	}
+	for campo := range campos {
+		campos[campo] = nil
+	}
+	return campos, nil
+}
+
+// HistorialPersona devuelve los cambios de la persona del más antiguo al más reciente.
+// Una persona guardada antes de que existiera el historial, o mientras no se inyecta,
+// puede no tener cambios: en ese caso se responde un historial vacío si la persona
+// está guardada y ErrNotFound si no
+func HistorialPersona(ctx context.Context, tipo models.TipoDocumento, documento string) ([]models.CambioPersona, error) {
+	if err := validarIdentidad(tipo, documento); err != nil {
+		return nil, err
+	}
+
+	cambios, err := cambiosGuardados(ctx, tipo, documento)
+	if err != nil {
+		return nil, err
+	}
+	if len(cambios) == 0 {
+		estado, err := documentoGuardado(ctx, tipo, documento)
+		if err != nil {
+			return nil, err
+		}
+		if estado == nil {
+			return nil, ErrNotFound
+		}
+	}
+	return cambios, nil
+}
+
+// cambiosGuardados lee el historial de la persona; sin historial inyectado no hay cambios
+func cambiosGuardados(ctx context.Context, tipo models.TipoDocumento, documento string) ([]models.CambioPersona, error) {
+	if Historial == nil {
+		return []models.CambioPersona{}, nil
+	}
+
+	cambios, err := Historial.ObtenerHistorial(ctx, tipo, documento)
+	if err != nil {
+		return nil, errorInfraestructura(err)
+	}
+	if cambios == nil {
+		cambios = []models.CambioPersona{}
+	}
+	return cambios, nil
+}
+
+// PersonaEnFecha reconstruye la persona tal como estaba en la fecha indicada. Si
+// sigue guardada se parte de su documento y se deshacen, del más reciente al más
+// antiguo, los cambios posteriores a esa fecha; si ya se purgó se repiten desde la
+// creación los cambios hasta esa fecha. Los cambios anteriores al historial no se
+// conocen, así que antes de su primera entrada la persona se muestra como quedó en
+// ella. Si la persona no existía se responde ErrNotFound
+func PersonaEnFecha(ctx context.Context, tipo models.TipoDocumento, documento string, fecha time.Time) (models.Persona, error) {
+	if err := validarIdentidad(tipo, documento); err != nil {
+		return models.Persona{}, err
+	}
+
+	cambios, err := cambiosGuardados(ctx, tipo, documento)
+	if err != nil {
+		return models.Persona{}, err
+	}
+
+	estado, err := documentoGuardado(ctx, tipo, documento)
+	if err != nil {
+		return models.Persona{}, err
+	}
+	if estado == nil && len(cambios) == 0 {
+		return models.Persona{}, ErrNotFound
+	}
+
+	if estado != nil {
+		for _, cambio := range slices.Backward(cambios) {
+			if !cambio.Fecha.After(fecha) {
+				break
+			}
+			aplicarValores(estado, cambio, func(c models.CambioCampo) any { return c.Antes })
+		}
+	} else {
+		// La purga automática del índice TTL no pasa por el servicio, así que no se
+		// puede partir del final del historial
+		estado = bson.M{}
+		for _, cambio := range cambios {
+			if cambio.Fecha.After(fecha) {
+				break
+			}
+			aplicarValores(estado, cambio, func(c models.CambioCampo) any { return c.Despues })
+		}
+	}
+	if _, existe := estado["documento"]; !existe {
+		return models.Persona{}, ErrNotFound
+	}
+	// Una persona sin la entrada de su creación sigue teniendo su fecha de creación
+	if creado, ok := estado["creado_en"].(primitive.DateTime); ok && creado.Time().After(fecha) {
+		return models.Persona{}, ErrNotFound
+	}
+
+	var persona models.Persona
+	datos, err := bson.Marshal(estado)
+	if err != nil {
+		return models.Persona{}, errorInfraestructura(err)
+	}
+	if err := bson.Unmarshal(datos, &persona); err != nil {
+		return models.Persona{}, errorInfraestructura(err)
+	}
+	return persona, nil
+}
+
+// aplicarValores asigna a cada campo del cambio el valor elegido; un valor nulo quita el campo
+func aplicarValores(estado bson.M, cambio models.CambioPersona, valor func(models.CambioCampo) any) {
+	for campo, c := range cambio.Cambios {
+		if v := valor(c); v != nil {
+			estado[campo] = v
+		} else {
+			delete(estado, campo)
+		}
+	}
+}
+
+// documentoGuardado devuelve la persona guardada, activa o eliminada, como documento,
+// o nil si ya se purgó
+func documentoGuardado(ctx context.Context, tipo models.TipoDocumento, documento string) (bson.M, error) {
+	persona, err := Repo.ObtenerPersonaPorDocumento(ctx, tipo, documento)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		persona, err = Repo.ObtenerPersonaEliminada(ctx, tipo, documento)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errorInfraestructura(err)
+	}
+
+	estado, err := comoDocumento(persona)
+	if err != nil {
+		return nil, errorInfraestructura(err)
+	}
+	return estado, nil
+}
@@ -0,0 +1,264 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/contexto"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func nuevoHistorial(t *testing.T) *mocks.MockHistorialRepo {
+	mockHistorial := new(mocks.MockHistorialRepo)
+	services.SetHistorialRepository(mockHistorial)
+	t.Cleanup(func() { services.SetHistorialRepository(nil) })
+	return mockHistorial
+}
+
+func TestCrearPersona_RegistraHistorial(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockHistorial := nuevoHistorial(t)
+
+	mockRepo.On("InsertarPersona", mock.Anything, mock.Anything).Return(primitive.NewObjectID(), nil)
+	mockHistorial.On("RegistrarCambio", mock.Anything, mock.MatchedBy(func(c models.CambioPersona) bool {
+		return c.Operacion == models.OperacionCrear &&
+			c.Documento == "123456" &&
+			c.Version == models.VersionInicial &&
+			c.Actor == "ana" &&
+			c.RequestID == "req-1" &&
+			c.Fecha.Equal(instante) &&
+			c.Cambios["nombre"] == models.CambioCampo{Antes: nil, Despues: "Juan"}
+	})).Return(nil)
+
+	ctx := contexto.ConUsuario(contexto.ConRequestID(context.Background(), "req-1"), "ana")
+	_, err := services.CrearPersona(ctx, models.Persona{
+		Documento: "123456",
+		Nombre:    "Juan",
+		Apellido:  "Pérez",
+		Edad:      30,
+		Correo:    "juan@example.com",
+		Telefono:  "3001234567",
+		Direccion: "Calle 123",
+	})
+
+	assert.NoError(t, err)
+	mockHistorial.AssertExpectations(t)
+}
+
+func TestModificarPersona_RegistraSoloLosCambios(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockHistorial := nuevoHistorial(t)
+
+	actual := models.Persona{
+		TipoDocumento: models.CC,
+		Documento:     "123456",
+		Nombre:        "Juan",
+		Apellido:      "Pérez",
+		Edad:          30,
+		Correo:        "juan@example.com",
+		Telefono:      "+573001234567",
+		Direccion:     "Calle 123",
+		Version:       1,
+	}
+	modificada := actual
+	modificada.Direccion = "Carrera 7"
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123456").Return(actual, nil)
+	mockRepo.On("ActualizarPersona", mock.Anything, models.CC, "123456", int64(1), mock.Anything).Return(nil)
+	mockHistorial.On("RegistrarCambio", mock.Anything, mock.MatchedBy(func(c models.CambioPersona) bool {
+		_, cambioNombre := c.Cambios["nombre"]
+		return c.Operacion == models.OperacionActualizar &&
+			c.Version == 2 &&
+			!cambioNombre &&
+			c.Cambios["direccion"] == models.CambioCampo{Antes: "Calle 123", Despues: "Carrera 7"}
+	})).Return(nil)
+
+	_, err := services.ModificarPersona(context.Background(), models.CC, "123456", services.VersionEsperada{Numero: 1}, modificada)
+
+	assert.NoError(t, err)
+	mockHistorial.AssertExpectations(t)
+}
+
+func TestBorrarPersona_FalloDelHistorialNoAfectaLaEscritura(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockHistorial := nuevoHistorial(t)
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123456").Return(models.Persona{Documento: "123456", Version: 1}, nil)
+	mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123456", int64(1), mock.Anything).Return(nil)
+	mockHistorial.On("RegistrarCambio", mock.Anything, mock.Anything).Return(errors.New("servidor no disponible"))
+
+	err := services.BorrarPersona(context.Background(), models.CC, "123456", services.VersionEsperada{Numero: 1})
+
+	assert.NoError(t, err)
+	mockHistorial.AssertExpectations(t)
+}
+
+func TestBorrarPersona_HistorialSobreviveALaCancelacion(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockHistorial := nuevoHistorial(t)
+
+	ctx, cancel := context.WithCancel(contexto.ConRequestID(context.Background(), "req-1"))
+
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123456").Return(models.Persona{Documento: "123456", Version: 1}, nil)
+	// El cliente se desconecta justo después de que la persona se guardó
+	mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123456", int64(1), mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).Return(nil)
+	mockHistorial.On("RegistrarCambio", mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Err() == nil && contexto.RequestID(ctx) == "req-1"
+	}), mock.Anything).Return(nil)
+
+	err := services.BorrarPersona(ctx, models.CC, "123456", services.VersionEsperada{Numero: 1})
+
+	assert.NoError(t, err)
+	mockHistorial.AssertExpectations(t)
+}
+
+func TestHistorialPersona_SinCambios(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "999").Return(models.Persona{}, mongo.ErrNoDocuments)
+	mockRepo.On("ObtenerPersonaEliminada", mock.Anything, models.CC, "999").Return(models.Persona{}, mongo.ErrNoDocuments)
+	mockHistorial := nuevoHistorial(t)
+	mockHistorial.On("ObtenerHistorial", mock.Anything, models.CC, "999").Return([]models.CambioPersona{}, nil)
+
+	_, err := services.HistorialPersona(context.Background(), models.CC, "999")
+
+	assert.ErrorIs(t, err, services.ErrNotFound)
+}
+
+func TestHistorialPersona_PersonaAnteriorAlHistorial(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{TipoDocumento: models.CC, Documento: "123"}, nil)
+
+	t.Run("Debe responder un historial vacío", func(t *testing.T) {
+		nuevoHistorial(t).On("ObtenerHistorial", mock.Anything, models.CC, "123").Return([]models.CambioPersona(nil), nil)
+
+		cambios, err := services.HistorialPersona(context.Background(), models.CC, "123")
+
+		assert.NoError(t, err)
+		assert.NotNil(t, cambios)
+		assert.Empty(t, cambios)
+	})
+
+	t.Run("Sin historial inyectado también debe responder vacío", func(t *testing.T) {
+		services.SetHistorialRepository(nil)
+
+		cambios, err := services.HistorialPersona(context.Background(), models.CC, "123")
+
+		assert.NoError(t, err)
+		assert.Empty(t, cambios)
+	})
+}
+
+func TestPersonaEnFecha(t *testing.T) {
+	creacion := instante.Add(-48 * time.Hour)
+	cambioTelefono := instante.Add(-24 * time.Hour)
+
+	historial := []models.CambioPersona{
+		{
+			Operacion: models.OperacionCrear,
+			Fecha:     creacion,
+			Cambios: map[string]models.CambioCampo{
+				"tipo_documento": {Despues: "CC"},
+				"documento":      {Despues: "123456"},
+				"nombre":         {Despues: "Juan"},
+				"telefono":       {Despues: "+573001234567"},
+				"version":        {Despues: int64(1)},
+			},
+		},
+		{
+			Operacion: models.OperacionActualizar,
+			Fecha:     cambioTelefono,
+			Cambios: map[string]models.CambioCampo{
+				"telefono": {Antes: "+573001234567", Despues: "+573109998877"},
+				"version":  {Antes: int64(1), Despues: int64(2)},
+			},
+		},
+	}
+	actual := models.Persona{TipoDocumento: models.CC, Documento: "123456", Nombre: "Juan", Telefono: "+573109998877", Version: 2}
+
+	nuevosRepos := func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123456").Return(actual, nil)
+		nuevoHistorial(t).On("ObtenerHistorial", mock.Anything, models.CC, "123456").Return(historial, nil)
+	}
+
+	t.Run("Debe deshacer los cambios posteriores a la fecha", func(t *testing.T) {
+		nuevosRepos(t)
+
+		persona, err := services.PersonaEnFecha(context.Background(), models.CC, "123456", cambioTelefono.Add(-time.Hour))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "+573001234567", persona.Telefono)
+		assert.Equal(t, int64(1), persona.Version)
+		assert.Equal(t, "Juan", persona.Nombre)
+	})
+
+	t.Run("Debe incluir los cambios hechos en la fecha exacta", func(t *testing.T) {
+		nuevosRepos(t)
+
+		persona, err := services.PersonaEnFecha(context.Background(), models.CC, "123456", cambioTelefono)
+
+		assert.NoError(t, err)
+		assert.Equal(t, actual, persona)
+	})
+
+	t.Run("Debe fallar si la persona aún no existía", func(t *testing.T) {
+		nuevosRepos(t)
+
+		_, err := services.PersonaEnFecha(context.Background(), models.CC, "123456", creacion.Add(-time.Minute))
+
+		assert.ErrorIs(t, err, services.ErrNotFound)
+	})
+
+	t.Run("Debe reconstruir una persona purgada", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123456").Return(models.Persona{}, mongo.ErrNoDocuments)
+		mockRepo.On("ObtenerPersonaEliminada", mock.Anything, models.CC, "123456").Return(models.Persona{}, mongo.ErrNoDocuments)
+		// La purga del índice TTL no deja entrada en el historial
+		nuevoHistorial(t).On("ObtenerHistorial", mock.Anything, models.CC, "123456").Return(historial, nil)
+
+		persona, err := services.PersonaEnFecha(context.Background(), models.CC, "123456", instante.Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, actual, persona)
+
+		persona, err = services.PersonaEnFecha(context.Background(), models.CC, "123456", cambioTelefono.Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, "+573001234567", persona.Telefono)
+
+		_, err = services.PersonaEnFecha(context.Background(), models.CC, "123456", creacion.Add(-time.Minute))
+		assert.ErrorIs(t, err, services.ErrNotFound)
+	})
+}
+
+func TestPersonaEnFecha_PersonaAnteriorAlHistorial(t *testing.T) {
+	creacion := instante.Add(-48 * time.Hour)
+	actual := models.Persona{TipoDocumento: models.CC, Documento: "123456", Nombre: "Juan", Version: 1, CreadoEn: creacion}
+
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123456").Return(actual, nil)
+	nuevoHistorial(t).On("ObtenerHistorial", mock.Anything, models.CC, "123456").Return([]models.CambioPersona{}, nil)
+
+	persona, err := services.PersonaEnFecha(context.Background(), models.CC, "123456", instante)
+	assert.NoError(t, err)
+	assert.Equal(t, actual, persona)
+
+	_, err = services.PersonaEnFecha(context.Background(), models.CC, "123456", creacion.Add(-time.Minute))
+	assert.ErrorIs(t, err, services.ErrNotFound)
+}
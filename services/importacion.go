@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+)
+
+// ModoImportacion indica qué hacer con las filas cuya persona ya existe
+type ModoImportacion string
+
+const (
+	// ModoOmitir deja intacta la persona existente
+	ModoOmitir ModoImportacion = "skip"
+	// ModoActualizar reemplaza los datos de la persona existente con los de la fila
+	ModoActualizar ModoImportacion = "upsert"
+)
+
+// Valido indica si el modo es uno de los admitidos
+func (m ModoImportacion) Valido() bool {
+	return m == ModoOmitir || m == ModoActualizar
+}
+
+// Resultados posibles de cada fila importada
+const (
+	FilaCreada      = "creada"
+	FilaActualizada = "actualizada"
+	FilaOmitida     = "omitida"
+	FilaFallida     = "fallida"
+)
+
+// ResultadoImportacion es el desenlace de una fila: su estado y, si no se creó ni
+// se actualizó, el motivo
+type ResultadoImportacion struct {
+	Estado string
+	Err    error
+}
+
+// ImportarPersonas guarda un bloque de filas de una importación. Las personas nuevas
+// se crean en un solo lote; las que ya existen se omiten o se actualizan según el modo
+func ImportarPersonas(ctx context.Context, personas []models.Persona, modo ModoImportacion) ([]ResultadoImportacion, error) {
+	creaciones, err := CrearPersonas(ctx, personas, false)
+	if err != nil {
+		return nil, err
+	}
+
+	resultados := make([]ResultadoImportacion, len(creaciones))
+	for i, creacion := range creaciones {
+		switch {
+		case creacion.Err == nil:
+			resultados[i] = ResultadoImportacion{Estado: FilaCreada}
+		case !errors.Is(creacion.Err, ErrDuplicate):
+			resultados[i] = ResultadoImportacion{Estado: FilaFallida, Err: creacion.Err}
+		case modo == ModoOmitir:
+			resultados[i] = ResultadoImportacion{Estado: FilaOmitida, Err: creacion.Err}
+		default:
+			resultados[i] = actualizarImportada(ctx, personas[i])
+		}
+	}
+	return resultados, nil
+}
+
+// actualizarImportada reemplaza los datos de una persona existente sin importar su versión
+func actualizarImportada(ctx context.Context, p models.Persona) ResultadoImportacion {
+	identidad := NormalizarPersona(p)
+
+	_, err := ModificarPersona(ctx, identidad.TipoDocumento, identidad.Documento, VersionCualquiera, p)
+	if errors.Is(err, ErrNotFound) {
+		// El documento está ocupado por una persona eliminada
+		err = fmt.Errorf("%w: la persona está eliminada y se debe restaurar antes de importarla", ErrDuplicate)
+	}
+	if err != nil {
+		return ResultadoImportacion{Estado: FilaFallida, Err: err}
+	}
+	return ResultadoImportacion{Estado: FilaActualizada}
+}
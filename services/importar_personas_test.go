@@ -0,0 +1,71 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestImportarPersonas(t *testing.T) {
+	duplicada := mongo.BulkWriteException{
+		WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 1, Code: 11000, Message: "E11000 duplicate key error"}}},
+	}
+	invalida := personaDeLote("789")
+	invalida.Edad = 0
+	lote := []models.Persona{personaDeLote("123"), personaDeLote("456"), invalida}
+
+	t.Run("Con skip debe omitir las existentes", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("InsertarPersonas", mock.Anything, conLongitud(2), false).Return(duplicada)
+
+		resultados, err := services.ImportarPersonas(context.Background(), lote, services.ModoOmitir)
+
+		assert.NoError(t, err)
+		assert.Equal(t, services.FilaCreada, resultados[0].Estado)
+		assert.Equal(t, services.FilaOmitida, resultados[1].Estado)
+		assert.ErrorIs(t, resultados[1].Err, services.ErrDuplicate)
+		assert.Equal(t, services.FilaFallida, resultados[2].Estado)
+		assert.ErrorIs(t, resultados[2].Err, services.ErrValidation)
+		mockRepo.AssertNotCalled(t, "ActualizarPersona", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Con upsert debe actualizar las existentes", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("InsertarPersonas", mock.Anything, conLongitud(2), false).Return(duplicada)
+		existente := services.NormalizarPersona(personaDeLote("456"))
+		existente.Nombre = "Beatriz"
+		existente.Version = 3
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "456").Return(existente, nil)
+		mockRepo.On("ActualizarPersona", mock.Anything, models.CC, "456", int64(3), mock.MatchedBy(func(p models.Persona) bool {
+			return p.Nombre == "Ana" && p.Version == 4
+		})).Return(nil)
+
+		resultados, err := services.ImportarPersonas(context.Background(), lote[:2], services.ModoActualizar)
+
+		assert.NoError(t, err)
+		assert.Equal(t, services.FilaCreada, resultados[0].Estado)
+		assert.Equal(t, services.FilaActualizada, resultados[1].Estado)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Con upsert no debe revivir una persona eliminada", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("InsertarPersonas", mock.Anything, conLongitud(2), false).Return(duplicada)
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "456").Return(models.Persona{}, mongo.ErrNoDocuments)
+
+		resultados, err := services.ImportarPersonas(context.Background(), lote[:2], services.ModoActualizar)
+
+		assert.NoError(t, err)
+		assert.Equal(t, services.FilaFallida, resultados[1].Estado)
+		assert.ErrorIs(t, resultados[1].Err, services.ErrDuplicate)
+	})
+}
@@ -1,45 +1,206 @@
 package services_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
 	"github.com/danysoftdev/microservicio-go-mongodb/models"
-    "github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
 	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func TestListarPersonas_Success(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
-    services.Repo = mockRepo
+	services.SetPersonaRepository(mockRepo)
 
-	personasMock := []models.Persona{
-		{Documento: "123", Nombre: "Ana", Apellido: "Díaz"},
-		{Documento: "456", Nombre: "Luis", Apellido: "Pérez"},
+	paginaMock := models.PaginaPersonas{
+		Datos: []models.Persona{
+			{Documento: "123", Nombre: "Ana", Apellido: "Díaz"},
+			{Documento: "456", Nombre: "Luis", Apellido: "Pérez"},
+		},
+		Total:  2,
+		Pagina: 1,
+		Limite: services.LimitePorDefecto,
 	}
 
-	mockRepo.On("ObtenerPersonas").Return(personasMock, nil)
-	services.SetPersonaRepository(mockRepo) // 👈 Aquí se inyecta el mock
+	// Sin parámetros se aplican la primera página y el límite por defecto
+	consultaEsperada := models.ConsultaPersonas{Pagina: 1, Limite: services.LimitePorDefecto}
+	mockRepo.On("ObtenerPersonasPaginadas", mock.Anything, consultaEsperada).Return(paginaMock, nil)
 
-	personas, err := services.ListarPersonas()
+	pagina, err := services.ListarPersonas(context.Background(), models.ConsultaPersonas{})
 
 	assert.NoError(t, err)
-	assert.Equal(t, 2, len(personas))
-	assert.Equal(t, "Ana", personas[0].Nombre)
+	assert.Equal(t, 2, len(pagina.Datos))
+	assert.Equal(t, int64(2), pagina.Total)
+	assert.Equal(t, "Ana", pagina.Datos[0].Nombre)
 	mockRepo.AssertExpectations(t)
 }
 
-func TestListarPersonas_Error(t *testing.T) {
+func TestListarPersonas_ConCursorYOrden(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	consulta := models.ConsultaPersonas{
+		Limite:      2,
+		Despues:     "65f1a2b3c4d5e6f708091a2b",
+		Orden:       "apellido",
+		Descendente: true,
+	}
+	esperada := consulta
+	esperada.Pagina = 1
+
+	mockRepo.On("ObtenerPersonasPaginadas", mock.Anything, esperada).Return(models.PaginaPersonas{
+		Datos:           []models.Persona{{Documento: "1"}, {Documento: "2"}},
+		Total:           10,
+		Limite:          2,
+		SiguienteCursor: "65f1a2b3c4d5e6f708091a2c",
+	}, nil)
+
+	pagina, err := services.ListarPersonas(context.Background(), consulta)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "65f1a2b3c4d5e6f708091a2c", pagina.SiguienteCursor)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListarPersonas_CursorInexistente(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	consulta := models.ConsultaPersonas{Pagina: 1, Limite: 5, Despues: "65f1a2b3c4d5e6f708091a2b"}
+	mockRepo.On("ObtenerPersonasPaginadas", mock.Anything, consulta).Return(models.PaginaPersonas{}, mongo.ErrNoDocuments)
+
+	_, err := services.ListarPersonas(context.Background(), consulta)
+
+	assert.ErrorIs(t, err, services.ErrCursorInvalido)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListarPersonas_ConsultaInvalida(t *testing.T) {
 	mockRepo := new(mocks.MockPersonaRepo)
-    services.Repo = mockRepo
+	services.SetPersonaRepository(mockRepo)
 
-	mockRepo.On("ObtenerPersonas").Return([]models.Persona(nil), errors.New("fallo al obtener"))
+	casos := []struct {
+		nombre        string
+		consulta      models.ConsultaPersonas
+		errorEsperado string
+	}{
+		{"Límite excedido", models.ConsultaPersonas{Limite: 500}, "el límite debe estar entre 1 y 100"},
+		{"Límite negativo", models.ConsultaPersonas{Limite: -1}, "el límite debe estar entre 1 y 100"},
+		{"Página negativa", models.ConsultaPersonas{Pagina: -2}, "la página debe ser un número entero mayor a 0"},
+		{"Página con cursor", models.ConsultaPersonas{Pagina: 2, Despues: "65f1a2b3c4d5e6f708091a2b"}, "no se puede combinar la página con el cursor"},
+		{"Cursor mal formado", models.ConsultaPersonas{Despues: "abc"}, "el cursor es inválido"},
+		{"Orden no permitido", models.ConsultaPersonas{Orden: "correo"}, `no se puede ordenar por el campo "correo"`},
+	}
+
+	for _, tt := range casos {
+		t.Run(tt.nombre, func(t *testing.T) {
+			_, err := services.ListarPersonas(context.Background(), tt.consulta)
+			assert.EqualError(t, err, tt.errorEsperado)
+		})
+	}
+
+	mockRepo.AssertNotCalled(t, "ObtenerPersonasPaginadas", mock.Anything, mock.Anything)
+}
+
+func TestListarPersonas_Error(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
 	services.SetPersonaRepository(mockRepo)
 
-	personas, err := services.ListarPersonas()
+	mockRepo.On("ObtenerPersonasPaginadas", mock.Anything, models.ConsultaPersonas{Pagina: 1, Limite: services.LimitePorDefecto}).
+		Return(models.PaginaPersonas{}, errors.New("fallo al obtener"))
+
+	pagina, err := services.ListarPersonas(context.Background(), models.ConsultaPersonas{})
 
 	assert.Error(t, err)
-	assert.Nil(t, personas)
+	assert.Nil(t, pagina.Datos)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestListarPersonas_ConFiltros(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	min, max := 18, 30
+	consulta := models.ConsultaPersonas{Filtro: models.FiltroPersonas{
+		Apellido:        "  Díaz ",
+		DominioCorreo:   "@example.com",
+		EdadMin:         &min,
+		EdadMax:         &max,
+		PrefijoTelefono: "+57300",
+		Direccion:       "Medellín",
+	}}
+
+	esperada := models.ConsultaPersonas{Pagina: 1, Limite: services.LimitePorDefecto, Filtro: models.FiltroPersonas{
+		Apellido:        "Díaz",
+		DominioCorreo:   "example.com",
+		EdadMin:         &min,
+		EdadMax:         &max,
+		PrefijoTelefono: "+57300",
+		Direccion:       "Medellín",
+	}}
+	mockRepo.On("ObtenerPersonasPaginadas", mock.Anything, esperada).Return(models.PaginaPersonas{Datos: []models.Persona{}}, nil)
+
+	_, err := services.ListarPersonas(context.Background(), consulta)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListarPersonas_PrefijoTelefonoLocal(t *testing.T) {
+	casos := map[string]string{
+		"300":    "+57300",
+		" 601 ":  "+57601",
+		"57300":  "+57300",
+		"+1212":  "+1212",
+		"+57300": "+57300",
+	}
+
+	for prefijo, esperado := range casos {
+		t.Run(prefijo, func(t *testing.T) {
+			mockRepo := new(mocks.MockPersonaRepo)
+			services.SetPersonaRepository(mockRepo)
+
+			esperada := models.ConsultaPersonas{Pagina: 1, Limite: services.LimitePorDefecto, Filtro: models.FiltroPersonas{PrefijoTelefono: esperado}}
+			mockRepo.On("ObtenerPersonasPaginadas", mock.Anything, esperada).Return(models.PaginaPersonas{Datos: []models.Persona{}}, nil)
+
+			_, err := services.ListarPersonas(context.Background(), models.ConsultaPersonas{Filtro: models.FiltroPersonas{PrefijoTelefono: prefijo}})
+
+			assert.NoError(t, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListarPersonas_FiltrosInvalidos(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+
+	negativa, diez, veinte := -1, 10, 20
+
+	casos := []struct {
+		nombre        string
+		filtro        models.FiltroPersonas
+		errorEsperado string
+	}{
+		{"Edad mínima negativa", models.FiltroPersonas{EdadMin: &negativa}, "edad_min no puede ser negativa"},
+		{"Edad máxima negativa", models.FiltroPersonas{EdadMax: &negativa}, "edad_max no puede ser negativa"},
+		{"Rango invertido", models.FiltroPersonas{EdadMin: &veinte, EdadMax: &diez}, "edad_min no puede ser mayor que edad_max"},
+		{"Dominio sin punto", models.FiltroPersonas{DominioCorreo: "localhost"}, "el dominio de correo es inválido"},
+		{"Dominio con arroba", models.FiltroPersonas{DominioCorreo: "ana@example.com"}, "el dominio de correo es inválido"},
+		{"Prefijo con letras", models.FiltroPersonas{PrefijoTelefono: "30a"}, "el prefijo de teléfono solo puede contener dígitos y un + inicial"},
+	}
+
+	for _, tt := range casos {
+		t.Run(tt.nombre, func(t *testing.T) {
+			_, err := services.ListarPersonas(context.Background(), models.ConsultaPersonas{Filtro: tt.filtro})
+			assert.EqualError(t, err, tt.errorEsperado)
+		})
+	}
+
+	mockRepo.AssertNotCalled(t, "ObtenerPersonasPaginadas", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,17 @@
+package services_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+)
+
+// instante es la hora fija con la que el servicio marca las escrituras durante las pruebas
+var instante = time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+	services.SetReloj(func() time.Time { return instante })
+	os.Exit(m.Run())
+}
@@ -1,13 +1,16 @@
 package services_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
+	"github.com/danysoftdev/microservicio-go-mongodb/contexto"
 	"github.com/danysoftdev/microservicio-go-mongodb/models"
 	"github.com/danysoftdev/microservicio-go-mongodb/services"
 	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -16,26 +19,35 @@ func TestModificarPersona(t *testing.T) {
 	services.SetPersonaRepository(mockRepo)
 
 	personaValida := models.Persona{
-		Documento: "123",
-		Nombre:    "Laura",
-		Apellido:  "Gomez",
-		Edad:      25,
-		Correo:    "laura@example.com",
-		Telefono:  "555-1234",
-		Direccion: "Calle Falsa 123",
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Laura",
+		Apellido:      "Gomez",
+		Edad:          25,
+		Correo:        "laura@example.com",
+		Telefono:      "+573005551234",
+		Direccion:     "Calle Falsa 123",
+		Version:       1,
 	}
 
+	// La escritura guarda la persona con la versión siguiente
+	actualizada := personaValida
+	actualizada.Version = 2
+	actualizada.ActualizadoEn = instante
+	actualizada.ActualizadoPor = services.UsuarioAnonimo
+
 	t.Run("Debe modificar una persona exitosamente", func(t *testing.T) {
-		mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(personaValida, nil)
-		mockRepo.On("ActualizarPersona", "123", personaValida).Return(nil)
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(personaValida, nil)
+		mockRepo.On("ActualizarPersona", mock.Anything, models.CC, "123", int64(1), actualizada).Return(nil)
 
-		err := services.ModificarPersona("123", personaValida)
+		modificada, err := services.ModificarPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, personaValida)
 		assert.NoError(t, err)
+		assert.Equal(t, actualizada, modificada)
 		mockRepo.AssertExpectations(t)
 	})
 
 	t.Run("Debe fallar si el documento está vacío", func(t *testing.T) {
-		err := services.ModificarPersona("", personaValida)
+		_, err := services.ModificarPersona(context.Background(), models.CC, "", services.VersionEsperada{Numero: 1}, personaValida)
 		assert.EqualError(t, err, "el documento no puede estar vacío")
 	})
 
@@ -43,7 +55,7 @@ func TestModificarPersona(t *testing.T) {
 		invalida := personaValida
 		invalida.Nombre = ""
 
-		err := services.ModificarPersona("123", invalida)
+		_, err := services.ModificarPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, invalida)
 		assert.EqualError(t, err, "el nombre no puede estar vacío")
 	})
 
@@ -51,17 +63,47 @@ func TestModificarPersona(t *testing.T) {
 		nueva := personaValida
 		nueva.Documento = "456"
 
-		err := services.ModificarPersona("123", nueva)
+		_, err := services.ModificarPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, nueva)
+		assert.ErrorIs(t, err, services.ErrImmutableField)
 		assert.EqualError(t, err, "no se puede modificar el documento de una persona")
 	})
 
-    t.Run("Debe fallar si la persona no existe", func(t *testing.T) {
+	t.Run("Debe fallar si se intenta cambiar el tipo de documento", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123456").Return(personaValida, nil)
+
+		nueva := personaValida
+		nueva.TipoDocumento = models.CE
+		nueva.Documento = "123456"
+
+		_, err := services.ModificarPersona(context.Background(), models.CC, "123456", services.VersionEsperada{Numero: 1}, nueva)
+		assert.ErrorIs(t, err, services.ErrImmutableField)
+		mockRepo.AssertNotCalled(t, "ActualizarPersona", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Debe tomar el tipo de la ruta si el cuerpo no lo trae", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+
+		sinTipo := personaValida
+		sinTipo.TipoDocumento = ""
+
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(personaValida, nil)
+		mockRepo.On("ActualizarPersona", mock.Anything, models.CC, "123", int64(1), actualizada).Return(nil)
+
+		_, err := services.ModificarPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, sinTipo)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Debe fallar si la persona no existe", func(t *testing.T) {
 		mockRepo := new(mocks.MockPersonaRepo)
 		services.SetPersonaRepository(mockRepo)
 
-		mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(models.Persona{}, mongo.ErrNoDocuments)
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(models.Persona{}, mongo.ErrNoDocuments)
 
-		err := services.ModificarPersona("123", personaValida)
+		_, err := services.ModificarPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, personaValida)
 		assert.EqualError(t, err, "persona no encontrada")
 		mockRepo.AssertExpectations(t)
 	})
@@ -70,12 +112,64 @@ func TestModificarPersona(t *testing.T) {
 		mockRepo := new(mocks.MockPersonaRepo)
 		services.SetPersonaRepository(mockRepo)
 
-		mockRepo.On("ObtenerPersonaPorDocumento", "123").Return(personaValida, nil)
-		mockRepo.On("ActualizarPersona", "123", personaValida).Return(errors.New("error al actualizar"))
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(personaValida, nil)
+		mockRepo.On("ActualizarPersona", mock.Anything, models.CC, "123", int64(1), actualizada).Return(errors.New("error al actualizar"))
 
-		err := services.ModificarPersona("123", personaValida)
+		_, err := services.ModificarPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, personaValida)
 		assert.EqualError(t, err, "error al actualizar")
 		mockRepo.AssertExpectations(t)
 	})
 
+	t.Run("Debe fallar si la versión no coincide", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(actualizada, nil)
+
+		_, err := services.ModificarPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, personaValida)
+		assert.ErrorIs(t, err, services.ErrPreconditionFailed)
+		mockRepo.AssertNotCalled(t, "ActualizarPersona", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Debe fallar si otra operación escribe primero", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(personaValida, nil)
+		mockRepo.On("ActualizarPersona", mock.Anything, models.CC, "123", int64(1), actualizada).Return(mongo.ErrNoDocuments)
+
+		_, err := services.ModificarPersona(context.Background(), models.CC, "123", services.VersionCualquiera, personaValida)
+		assert.ErrorIs(t, err, services.ErrPreconditionFailed)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Debe conservar la creación y registrar quién modifica", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+
+		guardada := personaValida
+		guardada.CreadoEn = instante.AddDate(0, -1, 0)
+		guardada.CreadoPor = "registro"
+		guardada.ActualizadoEn = guardada.CreadoEn
+		guardada.ActualizadoPor = "registro"
+
+		// El cliente intenta reescribir la auditoría
+		entrada := personaValida
+		entrada.CreadoPor = "impostor"
+		entrada.ActualizadoEn = instante.AddDate(1, 0, 0)
+
+		esperada := actualizada
+		esperada.CreadoEn = guardada.CreadoEn
+		esperada.CreadoPor = "registro"
+		esperada.ActualizadoEn = instante
+		esperada.ActualizadoPor = "operador"
+
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(guardada, nil)
+		mockRepo.On("ActualizarPersona", mock.Anything, models.CC, "123", int64(1), esperada).Return(nil)
+
+		ctx := contexto.ConUsuario(context.Background(), "operador")
+		_, err := services.ModificarPersona(ctx, models.CC, "123", services.VersionEsperada{Numero: 1}, entrada)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
 }
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/metricas"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TamanoMuestra es la cantidad de personas que trae la simulación de una operación masiva
+const TamanoMuestra = 10
+
+// camposMasivos son los campos que se pueden asignar en una actualización masiva. La
+// identidad, la versión y la auditoría no se cambian de esta forma
+var camposMasivos = []string{"nombre", "apellido", "edad", "correo", "telefono", "direccion"}
+
+// camposReemplazables son los campos de texto libre que admiten reemplazos
+var camposReemplazables = []string{"nombre", "apellido", "direccion"}
+
+var errFiltroVacio = QueryError{Parametro: "filtro", Mensaje: "una operación masiva necesita al menos un filtro"}
+
+// modificacion es lo que cambia en una persona durante una operación masiva
+type modificacion struct {
+	persona models.Persona
+	cambios map[string]any
+}
+
+// ParchearPersonas aplica los mismos cambios a todas las personas activas del filtro.
+// Solo se escriben las personas en las que algún campo cambia, y cada una queda en el historial
+func ParchearPersonas(ctx context.Context, filtro models.FiltroPersonas, actualizacion models.ActualizacionMasiva, simulacion bool) (models.ResultadoMasivo, error) {
+	cambios, err := validarActualizacionMasiva(actualizacion)
+	if err != nil {
+		return models.ResultadoMasivo{}, err
+	}
+
+	filtro.IncluirEliminados = false
+	if err := validarFiltroMasivo(&filtro); err != nil {
+		return models.ResultadoMasivo{}, err
+	}
+
+	fecha, usuario := ahora(), actor(ctx)
+	diferencias := func(p models.Persona) (map[string]any, bool, error) {
+		antes, err := comoDocumento(p)
+		if err != nil {
+			return nil, false, errorInfraestructura(err)
+		}
+
+		diferencias := map[string]any{}
+		for campo, valor := range cambios {
+			if !reflect.DeepEqual(antes[campo], valor) {
+				diferencias[campo] = valor
+			}
+		}
+		for campo, r := range actualizacion.Reemplazos {
+			actual, _ := antes[campo].(string)
+			nuevo := strings.ReplaceAll(actual, r.Buscar, r.Reemplazo)
+			if nuevo == actual {
+				continue
+			}
+			if strings.TrimSpace(nuevo) == "" {
+				return nil, false, ValidationError{Campo: campo, Regla: ReglaRequerido, Mensaje: fmt.Sprintf("el reemplazo deja vacío el campo %s de la persona %s %s", campo, p.TipoDocumento, p.Documento)}
+			}
+			diferencias[campo] = nuevo
+		}
+
+		if len(diferencias) == 0 {
+			return nil, true, nil
+		}
+		maps.Copy(diferencias, estadoMasivo(p, fecha, usuario))
+		return diferencias, true, nil
+	}
+	escribir := func(versiones map[primitive.ObjectID]int64) ([]primitive.ObjectID, error) {
+		uniformes := maps.Clone(cambios)
+		uniformes["actualizado_en"] = fecha
+		uniformes["actualizado_por"] = usuario
+		return Repo.ActualizarPersonas(ctx, filtro, versiones, models.ActualizacionMasiva{Cambios: uniformes, Reemplazos: actualizacion.Reemplazos})
+	}
+
+	// Un reemplazo puede dejar vacío un campo de alguna persona. Como las personas se
+	// escriben por tandas, antes de escribir se recorren todas para no aplicar la
+	// operación a medias
+	if len(actualizacion.Reemplazos) > 0 && !simulacion {
+		if _, err := ejecutarMasivo(ctx, models.OperacionActualizar, filtro, true, diferencias, escribir); err != nil {
+			return models.ResultadoMasivo{}, err
+		}
+	}
+	return ejecutarMasivo(ctx, models.OperacionActualizar, filtro, simulacion, diferencias, escribir)
+}
+
+// BorrarPersonas marca como eliminadas todas las personas activas del filtro
+func BorrarPersonas(ctx context.Context, filtro models.FiltroPersonas, simulacion bool) (models.ResultadoMasivo, error) {
+	filtro.IncluirEliminados = false
+	if err := validarFiltroMasivo(&filtro); err != nil {
+		return models.ResultadoMasivo{}, err
+	}
+
+	fecha, usuario := ahora(), actor(ctx)
+	return ejecutarMasivo(ctx, models.OperacionEliminar, filtro, simulacion, func(p models.Persona) (map[string]any, bool, error) {
+		cambios := estadoMasivo(p, fecha, usuario)
+		cambios["eliminado_en"] = fecha
+		return cambios, true, nil
+	}, func(versiones map[primitive.ObjectID]int64) ([]primitive.ObjectID, error) {
+		return Repo.ActualizarPersonas(ctx, filtro, versiones, models.ActualizacionMasiva{Cambios: map[string]any{
+			"eliminado_en":    fecha,
+			"actualizado_en":  fecha,
+			"actualizado_por": usuario,
+		}})
+	})
+}
+
+// PurgarPersonas borra definitivamente las personas eliminadas que cumplen el filtro
+func PurgarPersonas(ctx context.Context, filtro models.FiltroPersonas, simulacion bool) (models.ResultadoMasivo, error) {
+	filtro.IncluirEliminados = true
+	if err := validarFiltroMasivo(&filtro); err != nil {
+		return models.ResultadoMasivo{}, err
+	}
+
+	return ejecutarMasivo(ctx, models.OperacionPurgar, filtro, simulacion, func(p models.Persona) (map[string]any, bool, error) {
+		if p.EliminadoEn.IsZero() {
+			return nil, false, nil
+		}
+		cambios, err := camposPurgados(p)
+		if err != nil {
+			return nil, false, errorInfraestructura(err)
+		}
+		return cambios, true, nil
+	}, func(versiones map[primitive.ObjectID]int64) ([]primitive.ObjectID, error) {
+		return Repo.PurgarPersonas(ctx, filtro, versiones)
+	})
+}
+
+// validarActualizacionMasiva revisa los campos de la actualización y devuelve los
+// cambios normalizados, con sus nombres y tipos de Mongo
+func validarActualizacionMasiva(a models.ActualizacionMasiva) (map[string]any, error) {
+	if len(a.Cambios) == 0 && len(a.Reemplazos) == 0 {
+		return nil, ValidationError{Campo: "cambios", Regla: ReglaRequerido, Mensaje: "se debe indicar al menos un cambio o un reemplazo"}
+	}
+
+	var errores ValidationErrors
+	for campo, valor := range a.Cambios {
+		switch {
+		case campo == "tipo_documento" || campo == "documento":
+			return nil, ErrImmutableField
+		case !slices.Contains(camposMasivos, campo):
+			errores = append(errores, ValidationError{Campo: campo, Regla: ReglaFormato, Mensaje: fmt.Sprintf("el campo %s no se puede modificar de forma masiva", campo)})
+		case valor == nil:
+			errores = append(errores, ValidationError{Campo: campo, Regla: ReglaRequerido, Mensaje: fmt.Sprintf("el campo %s no se puede eliminar", campo)})
+		}
+	}
+	for campo, r := range a.Reemplazos {
+		_, asignado := a.Cambios[campo]
+		switch {
+		case !slices.Contains(camposReemplazables, campo):
+			errores = append(errores, ValidationError{Campo: campo, Regla: ReglaFormato, Mensaje: fmt.Sprintf("el campo %s no admite reemplazos", campo)})
+		case r.Buscar == "":
+			errores = append(errores, ValidationError{Campo: campo, Regla: ReglaRequerido, Mensaje: fmt.Sprintf("el texto a buscar en %s no puede estar vacío", campo)})
+		case asignado:
+			errores = append(errores, ValidationError{Campo: campo, Regla: ReglaFormato, Mensaje: fmt.Sprintf("el campo %s no puede tener un cambio y un reemplazo a la vez", campo)})
+		}
+	}
+	if len(errores) > 0 {
+		slices.SortFunc(errores, func(a, b ValidationError) int { return cmp.Compare(a.Campo, b.Campo) })
+		return nil, errores
+	}
+
+	// Los valores se validan con las mismas reglas de una persona, sobre una
+	// plantilla que solo tiene los campos que cambian
+	plantilla, err := parchearPersona(models.Persona{}, a.Cambios)
+	if errors.Is(err, ErrValidation) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errorInfraestructura(err)
+	}
+	plantilla = NormalizarPersona(plantilla)
+
+	var invalidos ValidationErrors
+	if errors.As(ValidarPersona(plantilla), &invalidos) {
+		for _, e := range invalidos {
+			if _, ok := a.Cambios[e.Campo]; ok {
+				errores = append(errores, e)
+			}
+		}
+	}
+	if len(errores) > 0 {
+		return nil, errores
+	}
+
+	documento, err := comoDocumento(plantilla)
+	if err != nil {
+		return nil, errorInfraestructura(err)
+	}
+	cambios := make(map[string]any, len(a.Cambios))
+	for campo := range a.Cambios {
+		cambios[campo] = documento[campo]
+	}
+	return cambios, nil
+}
+
+// validarFiltroMasivo valida el filtro de una operación masiva, que no puede estar vacío
+func validarFiltroMasivo(filtro *models.FiltroPersonas) error {
+	if err := validarFiltro(filtro); err != nil {
+		return err
+	}
+	if filtro.Vacio() {
+		return errFiltroVacio
+	}
+	return nil
+}
+
+// estadoMasivo arma la versión y la auditoría que recibe cada persona en una escritura
+// masiva. La fecha y el usuario son los mismos para todo el lote
+func estadoMasivo(p models.Persona, fecha time.Time, usuario string) map[string]any {
+	return map[string]any{
+		"version":         p.Version + 1,
+		"actualizado_en":  fecha,
+		"actualizado_por": usuario,
+	}
+}
+
+// ejecutarMasivo recorre las personas del filtro y simula la operación o la escribe por
+// tandas de MaximoLote personas, sin cargarlas todas en memoria. cambiosDe indica si la
+// persona entra en la operación y qué cambia en ella, nil si no cambia nada. escribir
+// recibe la versión leída de cada persona de la tanda y devuelve las que se escribieron:
+// una persona que otra operación cambió después de la lectura se omite, para no pisar
+// ese cambio ni registrar valores que ya no son los guardados. Si una tanda falla, las
+// anteriores quedan escritas y en el historial
+func ejecutarMasivo(
+	ctx context.Context,
+	operacion models.Operacion,
+	filtro models.FiltroPersonas,
+	simulacion bool,
+	cambiosDe func(models.Persona) (map[string]any, bool, error),
+	escribir func(versiones map[primitive.ObjectID]int64) ([]primitive.ObjectID, error),
+) (models.ResultadoMasivo, error) {
+	resultado := models.ResultadoMasivo{Simulacion: simulacion}
+	var tanda []modificacion
+
+	escribirTanda := func() error {
+		if len(tanda) == 0 {
+			return nil
+		}
+		versiones := make(map[primitive.ObjectID]int64, len(tanda))
+		for _, m := range tanda {
+			versiones[m.persona.ID] = m.persona.Version
+		}
+
+		escritas, err := escribir(versiones)
+		if err != nil {
+			return errorInfraestructura(err)
+		}
+		resultado.Modificadas += int64(len(escritas))
+		metricas.ContarPersonas(operacion, int64(len(escritas)))
+
+		aplicadas := make(map[primitive.ObjectID]bool, len(escritas))
+		for _, id := range escritas {
+			aplicadas[id] = true
+		}
+		registrarCambios(ctx, operacion, slices.DeleteFunc(tanda, func(m modificacion) bool {
+			return !aplicadas[m.persona.ID]
+		}))
+		tanda = tanda[:0]
+		return nil
+	}
+
+	var errVisita error
+	err := Repo.RecorrerPersonas(ctx, filtro, func(p models.Persona) error {
+		cambios, coincide, err := cambiosDe(p)
+		if err != nil {
+			errVisita = err
+			return err
+		}
+		if !coincide {
+			return nil
+		}
+		resultado.Coincidentes++
+		if cambios == nil {
+			return nil
+		}
+
+		if simulacion {
+			resultado.Modificadas++
+			if len(resultado.Muestra) < TamanoMuestra {
+				resultado.Muestra = append(resultado.Muestra, p)
+			}
+			return nil
+		}
+		tanda = append(tanda, modificacion{persona: p, cambios: cambios})
+		if len(tanda) == MaximoLote {
+			errVisita = escribirTanda()
+		}
+		return errVisita
+	})
+	if err != nil && err == errVisita {
+		return models.ResultadoMasivo{}, err
+	}
+	if err != nil {
+		return models.ResultadoMasivo{}, errorInfraestructura(err)
+	}
+
+	if err := escribirTanda(); err != nil {
+		return models.ResultadoMasivo{}, err
+	}
+	return resultado, nil
+}
@@ -0,0 +1,239 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/metricas"
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func personasDeDireccion(direcciones ...string) []models.Persona {
+	personas := make([]models.Persona, len(direcciones))
+	for i, direccion := range direcciones {
+		personas[i] = models.Persona{
+			ID:            primitive.NewObjectID(),
+			TipoDocumento: models.CC,
+			Documento:     string(rune('1' + i)),
+			Nombre:        "Ana",
+			Telefono:      "+573001234567",
+			Direccion:     direccion,
+			Version:       1,
+		}
+	}
+	return personas
+}
+
+func nuevoRepoMasivo(personas []models.Persona) *mocks.MockPersonaRepo {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.SetPersonaRepository(mockRepo)
+	mockRepo.On("RecorrerPersonas", mock.Anything, mock.Anything).Return(personas, nil)
+	return mockRepo
+}
+
+func TestParchearPersonas(t *testing.T) {
+	filtro := models.FiltroPersonas{Direccion: "Bogta"}
+	reemplazo := models.ActualizacionMasiva{Reemplazos: map[string]models.Reemplazo{"direccion": {Buscar: "Bogta", Reemplazo: "Bogotá"}}}
+
+	t.Run("La simulación debe contar y mostrar sin escribir", func(t *testing.T) {
+		personas := personasDeDireccion("Calle 1, Bogta", "Calle 2, Bogta", "Calle 3, bogta")
+		mockRepo := nuevoRepoMasivo(personas)
+
+		resultado, err := services.ParchearPersonas(context.Background(), filtro, reemplazo, true)
+
+		assert.NoError(t, err)
+		assert.True(t, resultado.Simulacion)
+		assert.Equal(t, int64(3), resultado.Coincidentes)
+		assert.Equal(t, int64(2), resultado.Modificadas)
+		assert.Equal(t, personas[:2], resultado.Muestra)
+		mockRepo.AssertNotCalled(t, "ActualizarPersonas", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Debe actualizar solo las personas que cambian y registrarlas", func(t *testing.T) {
+		personas := personasDeDireccion("Calle 1, Bogta", "Calle 3, bogta")
+		mockRepo := nuevoRepoMasivo(personas)
+		mockRepo.On("ActualizarPersonas", mock.Anything, filtro, map[primitive.ObjectID]int64{personas[0].ID: 1}, models.ActualizacionMasiva{
+			Cambios:    map[string]any{"actualizado_en": instante, "actualizado_por": services.UsuarioAnonimo},
+			Reemplazos: reemplazo.Reemplazos,
+		}).Return([]primitive.ObjectID{personas[0].ID}, nil)
+		mockHistorial := nuevoHistorial(t)
+		mockHistorial.On("RegistrarCambios", mock.Anything, mock.MatchedBy(func(c []models.CambioPersona) bool {
+			return len(c) == 1 && c[0].Documento == "1" && c[0].Version == 2 &&
+				c[0].Cambios["direccion"] == models.CambioCampo{Antes: "Calle 1, Bogta", Despues: "Calle 1, Bogotá"}
+		})).Return(nil).Once()
+
+		resultado, err := services.ParchearPersonas(context.Background(), filtro, reemplazo, false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), resultado.Coincidentes)
+		assert.Equal(t, int64(1), resultado.Modificadas)
+		assert.Empty(t, resultado.Muestra)
+		mockRepo.AssertExpectations(t)
+		mockHistorial.AssertExpectations(t)
+	})
+
+	t.Run("Debe normalizar los valores asignados", func(t *testing.T) {
+		personas := personasDeDireccion("Calle 1")
+		personas[0].Telefono = "+573109998877"
+		mockRepo := nuevoRepoMasivo(personas)
+		mockRepo.On("ActualizarPersonas", mock.Anything, mock.Anything, mock.Anything, models.ActualizacionMasiva{
+			Cambios: map[string]any{"telefono": "+573001234567", "actualizado_en": instante, "actualizado_por": services.UsuarioAnonimo},
+		}).Return([]primitive.ObjectID{personas[0].ID}, nil)
+
+		_, err := services.ParchearPersonas(context.Background(), models.FiltroPersonas{Apellido: "Díaz"}, models.ActualizacionMasiva{
+			Cambios: map[string]any{"telefono": "300 123 4567"},
+		}, false)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Debe validar la actualización", func(t *testing.T) {
+		nuevoRepoMasivo(nil)
+
+		_, err := services.ParchearPersonas(context.Background(), filtro, models.ActualizacionMasiva{}, false)
+		assert.ErrorIs(t, err, services.ErrValidation)
+
+		_, err = services.ParchearPersonas(context.Background(), filtro, models.ActualizacionMasiva{Cambios: map[string]any{"documento": "9"}}, false)
+		assert.ErrorIs(t, err, services.ErrImmutableField)
+
+		_, err = services.ParchearPersonas(context.Background(), filtro, models.ActualizacionMasiva{
+			Cambios:    map[string]any{"version": 3, "correo": "ana", "nombre": nil},
+			Reemplazos: map[string]models.Reemplazo{"telefono": {Buscar: "300"}},
+		}, false)
+		var errores services.ValidationErrors
+		assert.ErrorAs(t, err, &errores)
+		assert.Len(t, errores, 3)
+		assert.Equal(t, "nombre", errores[0].Campo)
+		assert.Equal(t, "telefono", errores[1].Campo)
+		assert.Equal(t, "version", errores[2].Campo)
+
+		_, err = services.ParchearPersonas(context.Background(), filtro, models.ActualizacionMasiva{Cambios: map[string]any{"correo": "ana"}}, false)
+		assert.ErrorAs(t, err, &errores)
+		assert.Equal(t, "correo", errores[0].Campo)
+	})
+
+	t.Run("Debe exigir un filtro", func(t *testing.T) {
+		_, err := services.ParchearPersonas(context.Background(), models.FiltroPersonas{Apellido: "  "}, reemplazo, true)
+
+		assert.ErrorIs(t, err, services.ErrInvalidQuery)
+	})
+
+	t.Run("Debe validar todas las personas antes de escribir la primera tanda", func(t *testing.T) {
+		direcciones := make([]string, services.MaximoLote+1)
+		for i := range direcciones {
+			direcciones[i] = "Calle 1, Bogta"
+		}
+		direcciones[services.MaximoLote] = "Bogta"
+		mockRepo := nuevoRepoMasivo(personasDeDireccion(direcciones...))
+
+		_, err := services.ParchearPersonas(context.Background(), filtro, models.ActualizacionMasiva{
+			Reemplazos: map[string]models.Reemplazo{"direccion": {Buscar: "Bogta", Reemplazo: " "}},
+		}, false)
+
+		assert.ErrorIs(t, err, services.ErrValidation)
+		mockRepo.AssertNotCalled(t, "ActualizarPersonas", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Debe retornar error si falla la lectura", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("RecorrerPersonas", mock.Anything, mock.Anything).Return([]models.Persona{}, errors.New("cursor perdido"))
+
+		_, err := services.ParchearPersonas(context.Background(), filtro, reemplazo, false)
+
+		assert.ErrorIs(t, err, services.ErrInfrastructure)
+	})
+}
+
+func TestBorrarPersonas(t *testing.T) {
+	personas := personasDeDireccion("Calle 1", "Calle 2")
+	mockRepo := nuevoRepoMasivo(personas)
+	filtro := models.FiltroPersonas{DominioCorreo: "example.com"}
+	mockRepo.On("ActualizarPersonas", mock.Anything, filtro, map[primitive.ObjectID]int64{personas[0].ID: 1, personas[1].ID: 1}, models.ActualizacionMasiva{
+		Cambios: map[string]any{"eliminado_en": instante, "actualizado_en": instante, "actualizado_por": services.UsuarioAnonimo},
+	}).Return([]primitive.ObjectID{personas[0].ID, personas[1].ID}, nil)
+	eliminadas := metricas.OperacionesPersonas.WithLabelValues(string(models.OperacionEliminar))
+	antes := testutil.ToFloat64(eliminadas)
+
+	resultado, err := services.BorrarPersonas(context.Background(), models.FiltroPersonas{DominioCorreo: "@example.com"}, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), resultado.Coincidentes)
+	assert.Equal(t, int64(2), resultado.Modificadas)
+	assert.Equal(t, antes+2, testutil.ToFloat64(eliminadas))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgarPersonas(t *testing.T) {
+	personas := personasDeDireccion("Calle 1", "Calle 2")
+	personas[1].EliminadoEn = instante
+	mockRepo := nuevoRepoMasivo(personas)
+	filtro := models.FiltroPersonas{DominioCorreo: "example.com", IncluirEliminados: true}
+	mockRepo.On("PurgarPersonas", mock.Anything, filtro, map[primitive.ObjectID]int64{personas[1].ID: 1}).Return([]primitive.ObjectID{personas[1].ID}, nil)
+
+	resultado, err := services.PurgarPersonas(context.Background(), models.FiltroPersonas{DominioCorreo: "example.com"}, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), resultado.Coincidentes)
+	assert.Equal(t, int64(1), resultado.Modificadas)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBorrarPersonas_OmiteLasCambiadasDespuesDeLaLectura(t *testing.T) {
+	personas := personasDeDireccion("Calle 1", "Calle 2", "Calle 3")
+	mockRepo := nuevoRepoMasivo(personas)
+	// Otra operación cambió la segunda persona después de la lectura
+	mockRepo.On("ActualizarPersonas", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]primitive.ObjectID{personas[0].ID, personas[2].ID}, nil)
+	mockHistorial := nuevoHistorial(t)
+	mockHistorial.On("RegistrarCambios", mock.Anything, mock.MatchedBy(func(c []models.CambioPersona) bool {
+		return len(c) == 2 && c[0].Documento == "1" && c[1].Documento == "3"
+	})).Return(nil).Once()
+	eliminadas := metricas.OperacionesPersonas.WithLabelValues(string(models.OperacionEliminar))
+	antes := testutil.ToFloat64(eliminadas)
+
+	resultado, err := services.BorrarPersonas(context.Background(), models.FiltroPersonas{DominioCorreo: "example.com"}, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), resultado.Coincidentes)
+	assert.Equal(t, int64(2), resultado.Modificadas)
+	assert.Equal(t, antes+2, testutil.ToFloat64(eliminadas))
+	mockHistorial.AssertNotCalled(t, "RegistrarCambio", mock.Anything, mock.Anything)
+	mockHistorial.AssertExpectations(t)
+}
+
+func TestBorrarPersonas_EscribePorTandas(t *testing.T) {
+	direcciones := make([]string, services.MaximoLote+1)
+	for i := range direcciones {
+		direcciones[i] = "Calle 1"
+	}
+	personas := personasDeDireccion(direcciones...)
+	mockRepo := nuevoRepoMasivo(personas)
+	ids := make([]primitive.ObjectID, len(personas))
+	for i, p := range personas {
+		ids[i] = p.ID
+	}
+	tanda := func(n int) any {
+		return mock.MatchedBy(func(versiones map[primitive.ObjectID]int64) bool { return len(versiones) == n })
+	}
+	mockRepo.On("ActualizarPersonas", mock.Anything, mock.Anything, tanda(services.MaximoLote), mock.Anything).Return(ids[:services.MaximoLote], nil).Once()
+	mockRepo.On("ActualizarPersonas", mock.Anything, mock.Anything, tanda(1), mock.Anything).Return(ids[services.MaximoLote:], nil).Once()
+	mockHistorial := nuevoHistorial(t)
+	mockHistorial.On("RegistrarCambios", mock.Anything, mock.Anything).Return(nil).Twice()
+
+	resultado, err := services.BorrarPersonas(context.Background(), models.FiltroPersonas{DominioCorreo: "example.com"}, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(services.MaximoLote+1), resultado.Coincidentes)
+	assert.Equal(t, int64(services.MaximoLote+1), resultado.Modificadas)
+	mockRepo.AssertExpectations(t)
+	mockHistorial.AssertExpectations(t)
+}
@@ -0,0 +1,153 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestParchearPersona(t *testing.T) {
+	guardada := models.Persona{
+		ID:            primitive.NewObjectID(),
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Laura",
+		Apellido:      "Gomez",
+		Edad:          25,
+		Correo:        "laura@example.com",
+		Telefono:      "+573005551234",
+		Direccion:     "Calle Falsa 123",
+		Version:       1,
+	}
+
+	nuevoRepo := func() *mocks.MockPersonaRepo {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "123").Return(guardada, nil)
+		return mockRepo
+	}
+
+	t.Run("Debe actualizar solo los campos que cambian", func(t *testing.T) {
+		mockRepo := nuevoRepo()
+		mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123", int64(1), map[string]any{"telefono": "+573109998877", "version": int64(2), "actualizado_en": instante, "actualizado_por": services.UsuarioAnonimo}).Return(nil)
+
+		parcheada, err := services.ParchearPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, map[string]any{
+			"telefono": "310 999 8877",
+			"nombre":   "Laura",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "+573109998877", parcheada.Telefono)
+		assert.Equal(t, int64(2), parcheada.Version)
+		assert.Equal(t, instante, parcheada.ActualizadoEn)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("No debe escribir si el parche no cambia nada", func(t *testing.T) {
+		mockRepo := nuevoRepo()
+
+		parcheada, err := services.ParchearPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, map[string]any{"correo": "LAURA@example.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, guardada, parcheada)
+		mockRepo.AssertNotCalled(t, "ActualizarCampos", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Debe validar el documento resultante", func(t *testing.T) {
+		mockRepo := nuevoRepo()
+
+		// null elimina el campo, así que el nombre queda vacío
+		_, err := services.ParchearPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, map[string]any{
+			"nombre": nil,
+			"edad":   500,
+		})
+
+		var errores services.ValidationErrors
+		assert.ErrorAs(t, err, &errores)
+		assert.Len(t, errores, 2)
+		assert.Equal(t, "nombre", errores[0].Campo)
+		assert.Equal(t, "edad", errores[1].Campo)
+		mockRepo.AssertNotCalled(t, "ActualizarCampos", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Debe rechazar campos desconocidos o con tipo inválido", func(t *testing.T) {
+		nuevoRepo()
+
+		_, err := services.ParchearPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, map[string]any{"ciudad": "Cali"})
+		assert.ErrorIs(t, err, services.ErrValidation)
+		assert.EqualError(t, err, "el campo ciudad no existe")
+
+		_, err = services.ParchearPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, map[string]any{"edad": "treinta"})
+		assert.ErrorIs(t, err, services.ErrValidation)
+		assert.EqualError(t, err, "el campo edad tiene un tipo inválido")
+	})
+
+	t.Run("Debe fallar si se intenta cambiar el documento", func(t *testing.T) {
+		nuevoRepo()
+
+		_, err := services.ParchearPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, map[string]any{"documento": "456"})
+		assert.ErrorIs(t, err, services.ErrImmutableField)
+	})
+
+	t.Run("Debe ignorar el id y la versión del parche", func(t *testing.T) {
+		mockRepo := nuevoRepo()
+		mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123", int64(1), map[string]any{"edad": int32(26), "version": int64(2), "actualizado_en": instante, "actualizado_por": services.UsuarioAnonimo}).Return(nil)
+
+		_, err := services.ParchearPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, map[string]any{
+			"id":      primitive.NewObjectID().Hex(),
+			"version": 99,
+			"edad":    26,
+		})
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Debe ignorar los campos del servidor aunque estén mal formados", func(t *testing.T) {
+		mockRepo := nuevoRepo()
+		mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123", int64(1), map[string]any{"edad": int32(26), "version": int64(2), "actualizado_en": instante, "actualizado_por": services.UsuarioAnonimo}).Return(nil)
+
+		parche := map[string]any{
+			"id":             "zzz",
+			"version":        "nueva",
+			"creado_en":      "ayer",
+			"actualizado_en": map[string]any{"hora": 1},
+			"eliminado_en":   "mañana",
+			"edad":           26,
+		}
+		_, err := services.ParchearPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, parche)
+		assert.NoError(t, err)
+		assert.Contains(t, parche, "id")
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Debe fallar si la persona no existe", func(t *testing.T) {
+		mockRepo := new(mocks.MockPersonaRepo)
+		services.SetPersonaRepository(mockRepo)
+		mockRepo.On("ObtenerPersonaPorDocumento", mock.Anything, models.CC, "999").Return(models.Persona{}, mongo.ErrNoDocuments)
+
+		_, err := services.ParchearPersona(context.Background(), models.CC, "999", services.VersionEsperada{Numero: 1}, map[string]any{"nombre": "Ana"})
+		assert.ErrorIs(t, err, services.ErrNotFound)
+	})
+
+	t.Run("Debe retornar error si falla la actualización", func(t *testing.T) {
+		mockRepo := nuevoRepo()
+		mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123", int64(1), mock.Anything).Return(errors.New("error al actualizar"))
+
+		_, err := services.ParchearPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 1}, map[string]any{"direccion": "Carrera 7"})
+		assert.ErrorIs(t, err, services.ErrInfrastructure)
+	})
+
+	t.Run("Debe fallar si la versión no coincide", func(t *testing.T) {
+		mockRepo := nuevoRepo()
+
+		_, err := services.ParchearPersona(context.Background(), models.CC, "123", services.VersionEsperada{Numero: 2}, map[string]any{"nombre": "Ana"})
+		assert.ErrorIs(t, err, services.ErrPreconditionFailed)
+		mockRepo.AssertNotCalled(t, "ActualizarCampos", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
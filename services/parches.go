@@ -0,0 +1,123 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// aplicarMergePatch combina el parche con el documento según la RFC 7396: los
+// objetos se mezclan recursivamente, null elimina la clave y cualquier otro
+// valor reemplaza al anterior
+func aplicarMergePatch(destino, parche any) any {
+	cambios, ok := parche.(map[string]any)
+	if !ok {
+		return parche
+	}
+
+	original, ok := destino.(map[string]any)
+	if !ok {
+		original = map[string]any{}
+	}
+	for clave, valor := range cambios {
+		if valor == nil {
+			delete(original, clave)
+			continue
+		}
+		original[clave] = aplicarMergePatch(original[clave], valor)
+	}
+	return original
+}
+
+// camposDelServidor son las claves JSON que asigna el servidor. Se quitan del parche
+// antes de aplicarlo, así un valor mal formado en ellas no impide el resto del cambio
+var camposDelServidor = []string{"id", "version", "creado_en", "creado_por", "actualizado_en", "actualizado_por", "eliminado_en"}
+
+// parchearPersona aplica el merge patch sobre la representación JSON de la persona.
+// Los campos que no existen o tienen un tipo equivocado se reportan como errores de validación
+func parchearPersona(p models.Persona, parche map[string]any) (models.Persona, error) {
+	parche = maps.Clone(parche)
+	for _, campo := range camposDelServidor {
+		delete(parche, campo)
+	}
+
+	var documento map[string]any
+	actual, err := json.Marshal(p)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(actual, &documento); err != nil {
+		return p, err
+	}
+
+	resultado, err := json.Marshal(aplicarMergePatch(documento, parche))
+	if err != nil {
+		return p, err
+	}
+
+	var parcheada models.Persona
+	decoder := json.NewDecoder(bytes.NewReader(resultado))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&parcheada); err != nil {
+		return p, errorDeDecodificacion(err)
+	}
+	return parcheada, nil
+}
+
+// errorDeDecodificacion convierte los errores de encoding/json en errores de validación del
+// campo afectado. El documento lo armó el servidor a partir del parche, así que cualquier
+// error al leerlo viene de los valores que envió el cliente
+func errorDeDecodificacion(err error) error {
+	var tipoInvalido *json.UnmarshalTypeError
+	if errors.As(err, &tipoInvalido) {
+		return ValidationErrors{{Campo: tipoInvalido.Field, Regla: ReglaFormato, Mensaje: fmt.Sprintf("el campo %s tiene un tipo inválido", tipoInvalido.Field)}}
+	}
+
+	// encoding/json no expone un tipo para los campos desconocidos
+	if campo, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		campo = strings.Trim(campo, `"`)
+		return ValidationErrors{{Campo: campo, Regla: ReglaFormato, Mensaje: fmt.Sprintf("el campo %s no existe", campo)}}
+	}
+	return ValidationErrors{{Regla: ReglaFormato, Mensaje: fmt.Sprintf("el parche no forma una persona válida: %v", err)}}
+}
+
+// camposModificados devuelve, con sus nombres en Mongo, los campos que cambian entre
+// las dos versiones de la persona. El _id nunca se incluye
+func camposModificados(antes, despues models.Persona) (map[string]any, error) {
+	anterior, err := comoDocumento(antes)
+	if err != nil {
+		return nil, err
+	}
+	nuevo, err := comoDocumento(despues)
+	if err != nil {
+		return nil, err
+	}
+
+	cambios := map[string]any{}
+	for campo, valor := range nuevo {
+		if campo == "_id" {
+			continue
+		}
+		if !reflect.DeepEqual(anterior[campo], valor) {
+			cambios[campo] = valor
+		}
+	}
+	return cambios, nil
+}
+
+func comoDocumento(p models.Persona) (bson.M, error) {
+	datos, err := bson.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	var documento bson.M
+	err = bson.Unmarshal(datos, &documento)
+	return documento, err
+}
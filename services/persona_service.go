@@ -1,105 +1,490 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
+	"github.com/danysoftdev/microservicio-go-mongodb/metricas"
 	"github.com/danysoftdev/microservicio-go-mongodb/models"
 	"github.com/danysoftdev/microservicio-go-mongodb/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+const (
+	LimitePorDefecto = 20
+	LimiteMaximo     = 100
+)
+
+// VersionEsperada es la versión de la persona que el cliente espera modificar. El
+// número de versión vuelve a empezar si la persona se purga y su documento se
+// registra de nuevo, así que ID distingue a una persona de la que ocupó antes el
+// mismo documento; un ID vacío solo compara el número
+type VersionEsperada struct {
+	ID     primitive.ObjectID
+	Numero int64
+}
+
+// VersionCualquiera permite escribir sin importar la versión actual de la persona,
+// equivalente a enviar If-Match: *
+var VersionCualquiera = VersionEsperada{}
+
+var (
+	ErrCursorInvalido = QueryError{Parametro: "after", Mensaje: "el cursor es inválido"}
+	ErrBusquedaVacia  = QueryError{Parametro: "q", Mensaje: "el texto de búsqueda no puede estar vacío"}
+)
+
+var errDocumentoVacio = ValidationError{Campo: "documento", Regla: ReglaRequerido, Mensaje: "el documento no puede estar vacío"}
+
+var errTipoDocumentoInvalido = ValidationError{Campo: "tipo_documento", Regla: ReglaFormato, Mensaje: "el tipo de documento debe ser CC, TI, CE, NIT o PA"}
+
 var Repo repositories.PersonaRepository
 
 func SetPersonaRepository(r repositories.PersonaRepository) {
 	Repo = r
 }
 
+// ValidarPersona revisa todos los campos y devuelve un ValidationErrors con cada
+// falla encontrada, de modo que el cliente pueda corregirlas en un solo intento
 func ValidarPersona(p models.Persona) error {
+	var errores ValidationErrors
+
+	if !p.TipoDocumento.Valido() {
+		errores = append(errores, errTipoDocumentoInvalido)
+	}
 	if strings.TrimSpace(p.Documento) == "" {
-		return errors.New("el documento no puede estar vacío")
+		errores = append(errores, errDocumentoVacio)
+	} else if p.TipoDocumento.Valido() && !FormatoDocumentoValido(p.TipoDocumento, p.Documento) {
+		errores = append(errores, ValidationError{Campo: "documento", Regla: ReglaFormato, Mensaje: fmt.Sprintf("el documento no tiene un formato válido para el tipo %s", p.TipoDocumento)})
 	}
 	if strings.TrimSpace(p.Nombre) == "" {
-		return errors.New("el nombre no puede estar vacío")
+		errores = append(errores, ValidationError{Campo: "nombre", Regla: ReglaRequerido, Mensaje: "el nombre no puede estar vacío"})
 	}
 	if strings.TrimSpace(p.Apellido) == "" {
-		return errors.New("el apellido no puede estar vacío")
+		errores = append(errores, ValidationError{Campo: "apellido", Regla: ReglaRequerido, Mensaje: "el apellido no puede estar vacío"})
 	}
 	if p.Edad <= 0 {
-		return errors.New("la edad debe ser un número entero mayor a 0")
+		errores = append(errores, ValidationError{Campo: "edad", Regla: ReglaRango, Mensaje: "la edad debe ser un número entero mayor a 0"})
+	} else if p.Edad > edadMaxima {
+		errores = append(errores, ValidationError{Campo: "edad", Regla: ReglaRango, Mensaje: fmt.Sprintf("la edad no puede ser mayor a %d", edadMaxima)})
 	}
-	if strings.TrimSpace(p.Correo) == "" || !strings.Contains(p.Correo, "@") {
-		return errors.New("el correo es inválido")
+	if !CorreoValido(p.Correo) {
+		errores = append(errores, ValidationError{Campo: "correo", Regla: ReglaFormato, Mensaje: "el correo es inválido"})
 	}
 	if strings.TrimSpace(p.Telefono) == "" {
-		return errors.New("el teléfono no puede estar vacío")
+		errores = append(errores, ValidationError{Campo: "telefono", Regla: ReglaRequerido, Mensaje: "el teléfono no puede estar vacío"})
+	} else if _, ok := NormalizarTelefono(p.Telefono); !ok {
+		errores = append(errores, ValidationError{Campo: "telefono", Regla: ReglaFormato, Mensaje: "el teléfono debe ser un número colombiano de 10 dígitos o tener formato internacional +<indicativo><número>"})
 	}
 	if strings.TrimSpace(p.Direccion) == "" {
-		return errors.New("la dirección no puede estar vacía")
+		errores = append(errores, ValidationError{Campo: "direccion", Regla: ReglaRequerido, Mensaje: "la dirección no puede estar vacía"})
 	}
-	return nil
+
+	return errores.comoError()
 }
 
-func CrearPersona(p models.Persona) error {
+// CrearPersona valida y guarda una persona nueva. Devuelve la persona tal como quedó
+// guardada: normalizada, con su _id, la versión inicial y los datos de auditoría
+func CrearPersona(ctx context.Context, p models.Persona) (models.Persona, error) {
+	p = NormalizarPersona(p)
 	if err := ValidarPersona(p); err != nil {
-		return err
+		return models.Persona{}, err
 	}
 
-	_, err := Repo.ObtenerPersonaPorDocumento(p.Documento)
-	if err == nil {
-		return errors.New("ya existe una persona con ese documento")
+	p.Version = models.VersionInicial
+	marcarCreacion(ctx, &p)
+
+	// El índice único sobre (tipo_documento, documento) resuelve los duplicados,
+	// incluso entre peticiones concurrentes, así que no se consulta antes de insertar
+	id, err := Repo.InsertarPersona(ctx, p)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.Persona{}, ErrDuplicate
+	}
+	if err != nil {
+		return models.Persona{}, errorInfraestructura(err)
 	}
+	p.ID = id
 
-	return Repo.InsertarPersona(p)
+	metricas.ContarPersonas(models.OperacionCrear, 1)
+	registrarCreacion(ctx, p)
+	return p, nil
 }
 
-func ListarPersonas() ([]models.Persona, error) {
-	return Repo.ObtenerPersonas()
+// PrepararConsulta aplica los valores por defecto de la paginación y valida sus parámetros
+func PrepararConsulta(c models.ConsultaPersonas) (models.ConsultaPersonas, error) {
+	if c.Limite == 0 {
+		c.Limite = LimitePorDefecto
+	}
+	if c.Limite < 0 || c.Limite > LimiteMaximo {
+		return c, QueryError{Parametro: "limit", Mensaje: fmt.Sprintf("el límite debe estar entre 1 y %d", LimiteMaximo)}
+	}
+	if c.Pagina < 0 {
+		return c, QueryError{Parametro: "page", Mensaje: "la página debe ser un número entero mayor a 0"}
+	}
+	if c.Despues != "" && c.Pagina > 1 {
+		return c, QueryError{Parametro: "after", Mensaje: "no se puede combinar la página con el cursor"}
+	}
+	if c.Despues != "" && !primitive.IsValidObjectID(c.Despues) {
+		return c, ErrCursorInvalido
+	}
+	if c.Orden != "" && !slices.Contains(models.CamposOrdenables, c.Orden) {
+		return c, QueryError{Parametro: "sort", Mensaje: fmt.Sprintf("no se puede ordenar por el campo %q", c.Orden)}
+	}
+	if err := validarFiltro(&c.Filtro); err != nil {
+		return c, err
+	}
+	if c.Pagina == 0 {
+		c.Pagina = 1
+	}
+	return c, nil
 }
 
-func BuscarPersonaPorDocumento(doc string) (models.Persona, error) {
-	if strings.TrimSpace(doc) == "" {
-		return models.Persona{}, errors.New("el documento no puede estar vacío")
+// validarFiltro limpia los criterios del listado y rechaza los que no tienen sentido
+func validarFiltro(f *models.FiltroPersonas) error {
+	f.Apellido = strings.TrimSpace(f.Apellido)
+	f.Direccion = strings.TrimSpace(f.Direccion)
+	f.PrefijoTelefono = strings.TrimSpace(f.PrefijoTelefono)
+	f.DominioCorreo = strings.TrimPrefix(strings.TrimSpace(f.DominioCorreo), "@")
+
+	if f.EdadMin != nil && *f.EdadMin < 0 {
+		return QueryError{Parametro: "edad_min", Mensaje: "edad_min no puede ser negativa"}
+	}
+	if f.EdadMax != nil && *f.EdadMax < 0 {
+		return QueryError{Parametro: "edad_max", Mensaje: "edad_max no puede ser negativa"}
+	}
+	if f.EdadMin != nil && f.EdadMax != nil && *f.EdadMin > *f.EdadMax {
+		return QueryError{Parametro: "edad_min", Mensaje: "edad_min no puede ser mayor que edad_max"}
+	}
+	if f.DominioCorreo != "" && (strings.ContainsAny(f.DominioCorreo, "@ ") || !strings.Contains(f.DominioCorreo, ".")) {
+		return QueryError{Parametro: "correo_dominio", Mensaje: "el dominio de correo es inválido"}
 	}
+	if f.PrefijoTelefono != "" && !prefijoTelefonoValido(f.PrefijoTelefono) {
+		return QueryError{Parametro: "telefono_prefijo", Mensaje: "el prefijo de teléfono solo puede contener dígitos y un + inicial"}
+	}
+	f.PrefijoTelefono = normalizarPrefijoTelefono(f.PrefijoTelefono)
+	return nil
+}
 
-	persona, err := Repo.ObtenerPersonaPorDocumento(doc)
-	if err == mongo.ErrNoDocuments {
-		return models.Persona{}, errors.New("persona no encontrada")
+// normalizarPrefijoTelefono lleva el prefijo al formato E.164 con que se guardan los
+// teléfonos: un prefijo sin + que empieza por 57 ya trae el indicativo de Colombia y
+// cualquier otro se toma como el inicio de un número colombiano
+func normalizarPrefijoTelefono(prefijo string) string {
+	switch {
+	case prefijo == "" || strings.HasPrefix(prefijo, "+"):
+		return prefijo
+	case strings.HasPrefix(prefijo, "57"):
+		return "+" + prefijo
+	}
+	return "+57" + prefijo
+}
+
+func prefijoTelefonoValido(prefijo string) bool {
+	digitos := strings.TrimPrefix(prefijo, "+")
+	if digitos == "" {
+		return false
+	}
+	for _, c := range digitos {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func ListarPersonas(ctx context.Context, c models.ConsultaPersonas) (models.PaginaPersonas, error) {
+	c, err := PrepararConsulta(c)
+	if err != nil {
+		return models.PaginaPersonas{}, err
+	}
+
+	pagina, err := Repo.ObtenerPersonasPaginadas(ctx, c)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.PaginaPersonas{}, ErrCursorInvalido
+	}
+
+	return pagina, errorInfraestructura(err)
+}
+
+// BuscarPersonas busca personas por nombre, apellido o correo ordenadas por relevancia.
+// Un límite fuera de rango se ajusta al valor por defecto o al máximo permitido
+func BuscarPersonas(ctx context.Context, query string, limite int) ([]models.Persona, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrBusquedaVacia
+	}
+
+	if limite <= 0 {
+		limite = LimitePorDefecto
+	}
+	if limite > LimiteMaximo {
+		limite = LimiteMaximo
 	}
 
-	return persona, err
+	personas, err := Repo.BuscarPersonas(ctx, query, limite)
+	return personas, errorInfraestructura(err)
 }
 
-func ModificarPersona(documento string, p models.Persona) error {
+// validarIdentidad revisa el par (tipo, documento) con el que se ubica a una persona
+func validarIdentidad(tipo models.TipoDocumento, documento string) error {
 	if strings.TrimSpace(documento) == "" {
-		return errors.New("el documento no puede estar vacío")
+		return errDocumentoVacio
+	}
+	if !tipo.Valido() {
+		return errTipoDocumentoInvalido
+	}
+	return nil
+}
+
+func BuscarPersonaPorDocumento(ctx context.Context, tipo models.TipoDocumento, doc string) (models.Persona, error) {
+	if err := validarIdentidad(tipo, doc); err != nil {
+		return models.Persona{}, err
 	}
 
+	persona, err := Repo.ObtenerPersonaPorDocumento(ctx, tipo, doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Persona{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Persona{}, errorInfraestructura(err)
+	}
+
+	return persona, nil
+}
+
+// ModificarPersona reemplaza los datos de la persona. El tipo y el número de
+// documento identifican a la persona y no se pueden cambiar. La escritura solo
+// se aplica si la persona sigue en la versión indicada. Devuelve la persona tal
+// como quedó guardada
+func ModificarPersona(ctx context.Context, tipo models.TipoDocumento, documento string, esperada VersionEsperada, p models.Persona) (models.Persona, error) {
+	if err := validarIdentidad(tipo, documento); err != nil {
+		return models.Persona{}, err
+	}
+
+	if p.TipoDocumento == "" {
+		p.TipoDocumento = tipo
+	}
+	p = NormalizarPersona(p)
 	if err := ValidarPersona(p); err != nil {
+		return models.Persona{}, err
+	}
+
+	actual, err := BuscarPersonaPorDocumento(ctx, tipo, documento)
+	if err != nil {
+		return models.Persona{}, err
+	}
+	version, err := comprobarVersion(actual, esperada)
+	if err != nil {
+		return models.Persona{}, err
+	}
+
+	if p.TipoDocumento != tipo || p.Documento != documento {
+		return models.Persona{}, ErrImmutableField
+	}
+	// El _id no se reescribe: Mongo rechaza cualquier cambio sobre él
+	p.ID = primitive.NilObjectID
+	p.Version = version + 1
+	conservarAuditoria(&p, actual)
+	p.ActualizadoEn = ahora()
+	p.ActualizadoPor = actor(ctx)
+
+	cambios, err := camposModificados(actual, p)
+	if err != nil {
+		return models.Persona{}, errorInfraestructura(err)
+	}
+	if err := errorDeEscritura(Repo.ActualizarPersona(ctx, tipo, documento, version, p)); err != nil {
+		return models.Persona{}, err
+	}
+
+	metricas.ContarPersonas(models.OperacionActualizar, 1)
+	registrarCambio(ctx, models.OperacionActualizar, tipo, documento, &actual, cambios)
+	p.ID = actual.ID
+	return p, nil
+}
+
+// ParchearPersona aplica un JSON Merge Patch (RFC 7396) sobre la persona guardada,
+// valida el documento resultante y guarda solo los campos que cambiaron. Devuelve
+// la persona tal como quedó guardada
+func ParchearPersona(ctx context.Context, tipo models.TipoDocumento, documento string, esperada VersionEsperada, parche map[string]any) (models.Persona, error) {
+	actual, err := BuscarPersonaPorDocumento(ctx, tipo, documento)
+	if err != nil {
+		return models.Persona{}, err
+	}
+	version, err := comprobarVersion(actual, esperada)
+	if err != nil {
+		return models.Persona{}, err
+	}
+
+	parcheada, err := parchearPersona(actual, parche)
+	if errors.Is(err, ErrValidation) {
+		return models.Persona{}, err
+	}
+	if err != nil {
+		return models.Persona{}, errorInfraestructura(err)
+	}
+
+	// El _id, la versión y la auditoría los asigna el servidor y no se pueden
+	// cambiar desde el parche
+	parcheada.ID = actual.ID
+	parcheada.Version = actual.Version
+	conservarAuditoria(&parcheada, actual)
+	parcheada = NormalizarPersona(parcheada)
+	if err := ValidarPersona(parcheada); err != nil {
+		return models.Persona{}, err
+	}
+	if parcheada.TipoDocumento != tipo || parcheada.Documento != documento {
+		return models.Persona{}, ErrImmutableField
+	}
+
+	cambios, err := camposModificados(actual, parcheada)
+	if err != nil {
+		return models.Persona{}, errorInfraestructura(err)
+	}
+	if len(cambios) == 0 {
+		return actual, nil
+	}
+
+	parcheada.Version = version + 1
+	parcheada.ActualizadoEn = ahora()
+	parcheada.ActualizadoPor = actor(ctx)
+	maps.Copy(cambios, map[string]any{
+		"version":         parcheada.Version,
+		"actualizado_en":  parcheada.ActualizadoEn,
+		"actualizado_por": parcheada.ActualizadoPor,
+	})
+
+	if err := actualizarCampos(ctx, models.OperacionActualizar, tipo, documento, actual, version, cambios); err != nil {
+		return models.Persona{}, err
+	}
+	return parcheada, nil
+}
+
+// BorrarPersona marca la persona como eliminada si sigue en la versión indicada.
+// El registro se conserva hasta que se purga, de modo que se puede restaurar
+func BorrarPersona(ctx context.Context, tipo models.TipoDocumento, documento string, esperada VersionEsperada) error {
+	actual, err := BuscarPersonaPorDocumento(ctx, tipo, documento)
+	if err != nil {
 		return err
 	}
+	version, err := comprobarVersion(actual, esperada)
+	if err != nil {
+		return err
+	}
+
+	cambios := cambiosDeEstado(ctx, version)
+	cambios["eliminado_en"] = cambios["actualizado_en"]
 
-	_, err := Repo.ObtenerPersonaPorDocumento(documento)
-	if err == mongo.ErrNoDocuments {
-		return errors.New("persona no encontrada")
+	return actualizarCampos(ctx, models.OperacionEliminar, tipo, documento, actual, version, cambios)
+}
+
+// RestaurarPersona deshace la eliminación de una persona que todavía no se ha purgado
+func RestaurarPersona(ctx context.Context, tipo models.TipoDocumento, documento string, esperada VersionEsperada) error {
+	if err := validarIdentidad(tipo, documento); err != nil {
+		return err
 	}
 
-	if p.Documento != documento {
-		return errors.New("no se puede modificar el documento de una persona")
+	eliminada, err := Repo.ObtenerPersonaEliminada(ctx, tipo, documento)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return errorInfraestructura(err)
+	}
+	version, err := comprobarVersion(eliminada, esperada)
+	if err != nil {
+		return err
 	}
 
-	return Repo.ActualizarPersona(documento, p)
+	cambios := cambiosDeEstado(ctx, version)
+	cambios["eliminado_en"] = nil
+
+	return actualizarCampos(ctx, models.OperacionRestaurar, tipo, documento, eliminada, version, cambios)
 }
 
-func BorrarPersona(documento string) error {
-	if strings.TrimSpace(documento) == "" {
-		return errors.New("el documento no puede estar vacío")
+// PurgarPersona borra definitivamente una persona eliminada. Una persona activa
+// se debe eliminar primero, así que para ella se responde que no existe
+func PurgarPersona(ctx context.Context, tipo models.TipoDocumento, documento string) error {
+	if err := validarIdentidad(tipo, documento); err != nil {
+		return err
+	}
+
+	// Los valores de la persona solo se necesitan para dejarlos en el historial
+	var eliminada *models.Persona
+	if Historial != nil {
+		persona, err := Repo.ObtenerPersonaEliminada(ctx, tipo, documento)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return errorInfraestructura(err)
+		}
+		eliminada = &persona
+	}
+
+	err := Repo.PurgarPersona(ctx, tipo, documento)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return errorInfraestructura(err)
+	}
+	metricas.ContarPersonas(models.OperacionPurgar, 1)
+
+	if eliminada != nil {
+		campos, err := camposPurgados(*eliminada)
+		if err != nil {
+			return errorInfraestructura(err)
+		}
+		registrarCambio(ctx, models.OperacionPurgar, tipo, documento, eliminada, campos)
 	}
+	return nil
+}
 
-	_, err := Repo.ObtenerPersonaPorDocumento(documento)
-	if err == mongo.ErrNoDocuments {
-		return errors.New("persona no encontrada")
+// actualizarCampos escribe los cambios si la persona sigue en la versión indicada
+// y los deja en el historial
+func actualizarCampos(ctx context.Context, operacion models.Operacion, tipo models.TipoDocumento, documento string, actual models.Persona, version int64, cambios map[string]any) error {
+	if err := errorDeEscritura(Repo.ActualizarCampos(ctx, tipo, documento, version, cambios)); err != nil {
+		return err
 	}
 
-	return Repo.EliminarPersona(documento)
+	metricas.ContarPersonas(operacion, 1)
+	registrarCambio(ctx, operacion, tipo, documento, &actual, cambios)
+	return nil
+}
+
+// cambiosDeEstado arma los campos que cambian en toda escritura: la versión y la auditoría
+func cambiosDeEstado(ctx context.Context, version int64) map[string]any {
+	return map[string]any{
+		"version":         version + 1,
+		"actualizado_en":  ahora(),
+		"actualizado_por": actor(ctx),
+	}
+}
+
+// comprobarVersion devuelve la versión contra la que se debe escribir: la que
+// pidió el cliente, o la actual si acepta cualquiera
+func comprobarVersion(actual models.Persona, esperada VersionEsperada) (int64, error) {
+	if esperada == VersionCualquiera {
+		return actual.Version, nil
+	}
+	if !esperada.ID.IsZero() && esperada.ID != actual.ID {
+		return 0, ErrPreconditionFailed
+	}
+	if esperada.Numero != actual.Version {
+		return 0, ErrPreconditionFailed
+	}
+	return esperada.Numero, nil
+}
+
+// errorDeEscritura traduce el resultado de una escritura condicionada por versión.
+// Si no hubo coincidencia es porque otra operación cambió la persona entre la
+// lectura y la escritura
+func errorDeEscritura(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrPreconditionFailed
+	}
+	return errorInfraestructura(err)
 }
@@ -0,0 +1,93 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/danysoftdev/microservicio-go-mongodb/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRestaurarPersona_Exito(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	mockRepo.On("ObtenerPersonaEliminada", mock.Anything, models.CC, "123456").
+		Return(models.Persona{Documento: "123456", Version: 3, EliminadoEn: instante}, nil)
+	mockRepo.On("ActualizarCampos", mock.Anything, models.CC, "123456", int64(3), map[string]any{
+		"eliminado_en":    nil,
+		"version":         int64(4),
+		"actualizado_en":  instante,
+		"actualizado_por": services.UsuarioAnonimo,
+	}).Return(nil)
+
+	err := services.RestaurarPersona(context.Background(), models.CC, "123456", services.VersionCualquiera)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestaurarPersona_NoEliminada(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	mockRepo.On("ObtenerPersonaEliminada", mock.Anything, models.CC, "123456").
+		Return(models.Persona{}, mongo.ErrNoDocuments)
+
+	err := services.RestaurarPersona(context.Background(), models.CC, "123456", services.VersionCualquiera)
+
+	assert.ErrorIs(t, err, services.ErrNotFound)
+}
+
+func TestRestaurarPersona_VersionDesactualizada(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	mockRepo.On("ObtenerPersonaEliminada", mock.Anything, models.CC, "123456").
+		Return(models.Persona{Documento: "123456", Version: 3, EliminadoEn: instante}, nil)
+
+	err := services.RestaurarPersona(context.Background(), models.CC, "123456", services.VersionEsperada{Numero: 2})
+
+	assert.ErrorIs(t, err, services.ErrPreconditionFailed)
+	mockRepo.AssertNotCalled(t, "ActualizarCampos", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPurgarPersona_Exito(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	mockRepo.On("PurgarPersona", mock.Anything, models.CC, "123456").Return(nil)
+
+	err := services.PurgarPersona(context.Background(), models.CC, "123456")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgarPersona_NoEliminada(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	// Una persona activa no se puede purgar sin eliminarla antes
+	mockRepo.On("PurgarPersona", mock.Anything, models.CC, "123456").Return(mongo.ErrNoDocuments)
+
+	err := services.PurgarPersona(context.Background(), models.CC, "123456")
+
+	assert.ErrorIs(t, err, services.ErrNotFound)
+}
+
+func TestPurgarPersona_ErrorBaseDeDatos(t *testing.T) {
+	mockRepo := new(mocks.MockPersonaRepo)
+	services.Repo = mockRepo
+
+	mockRepo.On("PurgarPersona", mock.Anything, models.CC, "123456").Return(errors.New("servidor no disponible"))
+
+	err := services.PurgarPersona(context.Background(), models.CC, "123456")
+
+	assert.ErrorIs(t, err, services.ErrInfrastructure)
+}
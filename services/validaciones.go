@@ -0,0 +1,111 @@
+package services
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+)
+
+// EdadMaximaPorDefecto es la edad máxima aceptada si no se configura otra
+const EdadMaximaPorDefecto = 120
+
+var edadMaxima = EdadMaximaPorDefecto
+
+// SetEdadMaxima cambia la edad máxima aceptada por ValidarPersona
+func SetEdadMaxima(n int) {
+	edadMaxima = n
+}
+
+// formatosDocumento define el formato del número según el tipo de documento colombiano
+var formatosDocumento = map[models.TipoDocumento]*regexp.Regexp{
+	models.CC:  regexp.MustCompile(`^\d{3,10}$`),
+	models.TI:  regexp.MustCompile(`^\d{10,11}$`),
+	models.CE:  regexp.MustCompile(`^[A-Za-z0-9]{6,12}$`),
+	models.NIT: regexp.MustCompile(`^\d{9}-?\d$`), // Con dígito de verificación
+	models.PA:  regexp.MustCompile(`^[A-Za-z0-9]{5,20}$`),
+}
+
+// FormatoDocumentoValido indica si el número cumple el formato del tipo de documento
+func FormatoDocumentoValido(tipo models.TipoDocumento, numero string) bool {
+	formato, ok := formatosDocumento[tipo]
+	return ok && formato.MatchString(numero)
+}
+
+// NormalizarTipoDocumento pasa el tipo a mayúsculas y asume el tipo por defecto si viene vacío
+func NormalizarTipoDocumento(tipo models.TipoDocumento) models.TipoDocumento {
+	tipo = models.TipoDocumento(strings.ToUpper(strings.TrimSpace(string(tipo))))
+	if tipo == "" {
+		return models.TipoDocumentoPorDefecto
+	}
+	return tipo
+}
+
+// NormalizarDocumento lleva el número a la forma con que se guarda, para que el
+// índice único no admita la misma persona escrita de dos maneras: el NIT sin el
+// guion del dígito de verificación y la cédula de extranjería y el pasaporte en
+// mayúsculas
+func NormalizarDocumento(tipo models.TipoDocumento, numero string) string {
+	numero = strings.TrimSpace(numero)
+	switch tipo {
+	case models.NIT:
+		// Solo se quita el guion que separa el dígito de verificación
+		if len(numero) == 11 && numero[9] == '-' {
+			return numero[:9] + numero[10:]
+		}
+	case models.CE, models.PA:
+		return strings.ToUpper(numero)
+	}
+	return numero
+}
+
+// CorreoValido acepta únicamente una dirección simple (sin nombre visible) con dominio completo
+func CorreoValido(correo string) bool {
+	direccion, err := mail.ParseAddress(correo)
+	if err != nil || direccion.Address != correo || direccion.Name != "" {
+		return false
+	}
+	dominio := correo[strings.LastIndex(correo, "@")+1:]
+	return strings.Contains(dominio, ".") && !strings.HasSuffix(dominio, ".")
+}
+
+// NormalizarTelefono lleva el teléfono al formato E.164. Los números colombianos
+// de 10 dígitos (celulares que empiezan por 3 y fijos que empiezan por 60) reciben
+// el indicativo +57; los internacionales deben venir con el prefijo +
+func NormalizarTelefono(telefono string) (string, bool) {
+	limpio := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(telefono)
+
+	internacional := strings.HasPrefix(limpio, "+")
+	digitos := strings.TrimPrefix(limpio, "+")
+	if digitos == "" || strings.IndexFunc(digitos, func(c rune) bool { return c < '0' || c > '9' }) >= 0 {
+		return "", false
+	}
+
+	switch {
+	case internacional && len(digitos) >= 8 && len(digitos) <= 15 && digitos[0] != '0':
+		return "+" + digitos, true
+	case !internacional && len(digitos) == 10 && (digitos[0] == '3' || strings.HasPrefix(digitos, "60")):
+		return "+57" + digitos, true
+	case !internacional && len(digitos) == 12 && strings.HasPrefix(digitos, "57"):
+		return "+" + digitos, true
+	}
+	return "", false
+}
+
+// NormalizarPersona limpia los datos antes de validarlos y guardarlos: quita espacios,
+// pasa el correo a minúsculas y lleva el documento y el teléfono a su formato canónico
+func NormalizarPersona(p models.Persona) models.Persona {
+	p.TipoDocumento = NormalizarTipoDocumento(p.TipoDocumento)
+	p.Documento = NormalizarDocumento(p.TipoDocumento, p.Documento)
+	p.Nombre = strings.TrimSpace(p.Nombre)
+	p.Apellido = strings.TrimSpace(p.Apellido)
+	p.Correo = strings.ToLower(strings.TrimSpace(p.Correo))
+	p.Telefono = strings.TrimSpace(p.Telefono)
+	p.Direccion = strings.TrimSpace(p.Direccion)
+
+	if telefono, ok := NormalizarTelefono(p.Telefono); ok {
+		p.Telefono = telefono
+	}
+	return p
+}
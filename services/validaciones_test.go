@@ -0,0 +1,165 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/danysoftdev/microservicio-go-mongodb/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizarTelefono(t *testing.T) {
+	casos := []struct {
+		entrada  string
+		esperado string
+		valido   bool
+	}{
+		{"3001234567", "+573001234567", true},
+		{"300 123 4567", "+573001234567", true},
+		{"(601) 234-5678", "+576012345678", true},
+		{"573001234567", "+573001234567", true},
+		{"+57 300 123 4567", "+573001234567", true},
+		{"+1 (415) 555-2671", "+14155552671", true},
+		{"1234567", "", false},
+		{"2001234567", "", false},
+		{"+0123456789", "", false},
+		{"+12", "", false},
+		{"300-ABC-4567", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range casos {
+		t.Run(tt.entrada, func(t *testing.T) {
+			telefono, ok := services.NormalizarTelefono(tt.entrada)
+			assert.Equal(t, tt.valido, ok)
+			assert.Equal(t, tt.esperado, telefono)
+		})
+	}
+}
+
+func TestCorreoValido(t *testing.T) {
+	assert.True(t, services.CorreoValido("ana@example.com"))
+	assert.True(t, services.CorreoValido("ana.diaz+trabajo@sub.example.co"))
+
+	assert.False(t, services.CorreoValido("anaexample.com"))
+	assert.False(t, services.CorreoValido("ana@localhost"))
+	assert.False(t, services.CorreoValido("ana@example."))
+	assert.False(t, services.CorreoValido("Ana <ana@example.com>"))
+	assert.False(t, services.CorreoValido("ana@@example.com"))
+	assert.False(t, services.CorreoValido(""))
+}
+
+func TestFormatoDocumentoValido(t *testing.T) {
+	casos := []struct {
+		tipo   models.TipoDocumento
+		numero string
+		valido bool
+	}{
+		{"CC", "1020304050", true},
+		{"CC", "12", false},
+		{"CC", "10203040AB", false},
+		{"TI", "1020304050", true},
+		{"TI", "102030", false},
+		{"CE", "E12345", true},
+		{"CE", "123", false},
+		{"NIT", "900123456-7", true},
+		{"NIT", "9001234567", true},
+		{"NIT", "900-123", false},
+		{"PA", "AB123456", true},
+		{"PA", "AB-1", false},
+		{"XX", "123456", false},
+		{"cc", "1020304050", false},
+	}
+
+	for _, tt := range casos {
+		t.Run(string(tt.tipo)+"_"+tt.numero, func(t *testing.T) {
+			assert.Equal(t, tt.valido, services.FormatoDocumentoValido(tt.tipo, tt.numero))
+		})
+	}
+}
+
+func TestNormalizarDocumento(t *testing.T) {
+	tests := []struct {
+		tipo           models.TipoDocumento
+		numero, limpio string
+	}{
+		{models.NIT, "900123456-7", "9001234567"},
+		{models.NIT, " 9001234567 ", "9001234567"},
+		{models.NIT, "900-123", "900-123"},
+		{models.CE, "e12345", "E12345"},
+		{models.PA, " ab123456", "AB123456"},
+		{models.CC, " 123 ", "123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.tipo)+"_"+tt.numero, func(t *testing.T) {
+			assert.Equal(t, tt.limpio, services.NormalizarDocumento(tt.tipo, tt.numero))
+		})
+	}
+}
+
+func TestNormalizarPersona(t *testing.T) {
+	persona := services.NormalizarPersona(models.Persona{
+		TipoDocumento: " ti ",
+		Documento:     " 123 ",
+		Nombre:        "  Ana ",
+		Apellido:      "Díaz  ",
+		Correo:        " Ana.Diaz@Example.COM ",
+		Telefono:      "300 123 4567",
+		Direccion:     " Calle 1 ",
+	})
+
+	assert.Equal(t, models.Persona{
+		TipoDocumento: models.TI,
+		Documento:     "123",
+		Nombre:        "Ana",
+		Apellido:      "Díaz",
+		Correo:        "ana.diaz@example.com",
+		Telefono:      "+573001234567",
+		Direccion:     "Calle 1",
+	}, persona)
+
+	// Sin tipo se asume la cédula de ciudadanía
+	assert.Equal(t, models.CC, services.NormalizarPersona(models.Persona{}).TipoDocumento)
+}
+
+func TestValidarPersona_EdadMaxima(t *testing.T) {
+	defer services.SetEdadMaxima(services.EdadMaximaPorDefecto)
+
+	persona := models.Persona{
+		TipoDocumento: models.CC,
+		Documento:     "123",
+		Nombre:        "Ana",
+		Apellido:      "Díaz",
+		Edad:          500,
+		Correo:        "ana@example.com",
+		Telefono:      "+573001234567",
+		Direccion:     "Calle 1",
+	}
+
+	assert.EqualError(t, services.ValidarPersona(persona), "la edad no puede ser mayor a 120")
+
+	services.SetEdadMaxima(600)
+	assert.NoError(t, services.ValidarPersona(persona))
+}
+
+func TestValidarPersona_Formatos(t *testing.T) {
+	err := services.ValidarPersona(models.Persona{
+		TipoDocumento: models.CC,
+		Documento:     "12-3",
+		Nombre:        "Ana",
+		Apellido:      "Díaz",
+		Edad:          30,
+		Correo:        "ana@localhost",
+		Telefono:      "12345",
+		Direccion:     "Calle 1",
+	})
+
+	var errores services.ValidationErrors
+	assert.ErrorAs(t, err, &errores)
+	assert.Len(t, errores, 3)
+	assert.Equal(t, "documento", errores[0].Campo)
+	assert.Equal(t, "correo", errores[1].Campo)
+	assert.Equal(t, "telefono", errores[2].Campo)
+	assert.Equal(t, services.ReglaFormato, errores[2].Regla)
+}
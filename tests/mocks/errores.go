@@ -0,0 +1,8 @@
+package mocks
+
+import "go.mongodb.org/mongo-driver/mongo"
+
+// ErrLlaveDuplicada simula el error E11000 que devuelve MongoDB al violar un índice único
+var ErrLlaveDuplicada = mongo.WriteException{
+	WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error collection: personas index: identidad_unica"}},
+}
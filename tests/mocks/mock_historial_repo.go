@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/danysoftdev/microservicio-go-mongodb/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockHistorialRepo implementa la interfaz HistorialRepository para pruebas
+type MockHistorialRepo struct {
+	mock.Mock
+}
+
+func (m *MockHistorialRepo) RegistrarCambio(ctx context.Context, cambio models.CambioPersona) error {
+	args := m.Called(ctx, cambio)
+	return args.Error(0)
+}
+
+func (m *MockHistorialRepo) RegistrarCambios(ctx context.Context, cambios []models.CambioPersona) error {
+	args := m.Called(ctx, cambios)
+	return args.Error(0)
+}
+
+func (m *MockHistorialRepo) ObtenerHistorial(ctx context.Context, tipo models.TipoDocumento, doc string) ([]models.CambioPersona, error) {
+	args := m.Called(ctx, tipo, doc)
+	return args.Get(0).([]models.CambioPersona), args.Error(1)
+}
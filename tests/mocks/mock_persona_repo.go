@@ -1,8 +1,11 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/danysoftdev/microservicio-go-mongodb/models"
 	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // MockPersonaRepo implementa la interfaz PersonaRepository para pruebas
@@ -10,27 +13,69 @@ type MockPersonaRepo struct {
 	mock.Mock
 }
 
-func (m *MockPersonaRepo) InsertarPersona(p models.Persona) error {
-	args := m.Called(p)
+func (m *MockPersonaRepo) InsertarPersona(ctx context.Context, p models.Persona) (primitive.ObjectID, error) {
+	args := m.Called(ctx, p)
+	return args.Get(0).(primitive.ObjectID), args.Error(1)
+}
+
+func (m *MockPersonaRepo) InsertarPersonas(ctx context.Context, personas []models.Persona, atomica bool) error {
+	args := m.Called(ctx, personas, atomica)
 	return args.Error(0)
 }
 
-func (m *MockPersonaRepo) ObtenerPersonas() ([]models.Persona, error) {
-	args := m.Called()
+func (m *MockPersonaRepo) ObtenerPersonasPaginadas(ctx context.Context, consulta models.ConsultaPersonas) (models.PaginaPersonas, error) {
+	args := m.Called(ctx, consulta)
+	return args.Get(0).(models.PaginaPersonas), args.Error(1)
+}
+
+// RecorrerPersonas entrega a visitar las personas configuradas en el primer retorno
+// y luego devuelve el error configurado en el segundo
+func (m *MockPersonaRepo) RecorrerPersonas(ctx context.Context, filtro models.FiltroPersonas, visitar func(models.Persona) error) error {
+	args := m.Called(ctx, filtro)
+	for _, p := range args.Get(0).([]models.Persona) {
+		if err := visitar(p); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockPersonaRepo) BuscarPersonas(ctx context.Context, query string, limite int) ([]models.Persona, error) {
+	args := m.Called(ctx, query, limite)
 	return args.Get(0).([]models.Persona), args.Error(1)
 }
 
-func (m *MockPersonaRepo) ObtenerPersonaPorDocumento(doc string) (models.Persona, error) {
-	args := m.Called(doc)
+func (m *MockPersonaRepo) ObtenerPersonaPorDocumento(ctx context.Context, tipo models.TipoDocumento, doc string) (models.Persona, error) {
+	args := m.Called(ctx, tipo, doc)
 	return args.Get(0).(models.Persona), args.Error(1)
 }
 
-func (m *MockPersonaRepo) ActualizarPersona(doc string, p models.Persona) error {
-	args := m.Called(doc, p)
+func (m *MockPersonaRepo) ActualizarPersona(ctx context.Context, tipo models.TipoDocumento, doc string, version int64, p models.Persona) error {
+	args := m.Called(ctx, tipo, doc, version, p)
 	return args.Error(0)
 }
 
-func (m *MockPersonaRepo) EliminarPersona(doc string) error {
-	args := m.Called(doc)
+func (m *MockPersonaRepo) ActualizarCampos(ctx context.Context, tipo models.TipoDocumento, doc string, version int64, cambios map[string]any) error {
+	args := m.Called(ctx, tipo, doc, version, cambios)
 	return args.Error(0)
 }
+
+func (m *MockPersonaRepo) ObtenerPersonaEliminada(ctx context.Context, tipo models.TipoDocumento, doc string) (models.Persona, error) {
+	args := m.Called(ctx, tipo, doc)
+	return args.Get(0).(models.Persona), args.Error(1)
+}
+
+func (m *MockPersonaRepo) PurgarPersona(ctx context.Context, tipo models.TipoDocumento, doc string) error {
+	args := m.Called(ctx, tipo, doc)
+	return args.Error(0)
+}
+
+func (m *MockPersonaRepo) ActualizarPersonas(ctx context.Context, filtro models.FiltroPersonas, versiones map[primitive.ObjectID]int64, actualizacion models.ActualizacionMasiva) ([]primitive.ObjectID, error) {
+	args := m.Called(ctx, filtro, versiones, actualizacion)
+	return args.Get(0).([]primitive.ObjectID), args.Error(1)
+}
+
+func (m *MockPersonaRepo) PurgarPersonas(ctx context.Context, filtro models.FiltroPersonas, versiones map[primitive.ObjectID]int64) ([]primitive.ObjectID, error) {
+	args := m.Called(ctx, filtro, versiones)
+	return args.Get(0).([]primitive.ObjectID), args.Error(1)
+}